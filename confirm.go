@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"supalink/link"
+)
+
+// confirmationInput returns where the confirmation answer should be read
+// from. When stdin carries the path list, the answer comes from the terminal.
+// Closing it only closes the terminal, never stdin.
+func confirmationInput(settings settings) io.ReadCloser {
+	if !settings.FromStdin {
+		return io.NopCloser(os.Stdin)
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return io.NopCloser(os.Stdin)
+	}
+	return tty
+}
+
+// confirmEachSymlink asks about every planned symlink in turn. Declined
+// symlinks are excluded, and quitting cancels every symlink not asked about.
+// Running out of answers, or failing to read them, counts as quitting.
+func confirmEachSymlink(symlinks link.Plan, settings settings) {
+	tty := confirmationInput(settings)
+	defer tty.Close()
+	input := bufio.NewReader(tty)
+	readAnswer := func(prompt string) (string, bool) {
+		printStatus(settings, "%s", prompt)
+		answer, err := input.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		return answer, err == nil || answer != ""
+	}
+	quit := func(from int) {
+		for j := from; j < len(symlinks); j++ {
+			if symlinks[j].Action != link.SkipAction && symlinks[j].Action != link.ExcludeAction {
+				symlinks[j].Result = link.CancelledResult
+			}
+		}
+	}
+
+	for i := 0; i < len(symlinks); i++ {
+		symlink := &symlinks[i]
+		if symlink.Action == link.SkipAction || symlink.Action == link.ExcludeAction {
+			continue
+		}
+
+		printStatus(settings, "[%d/%d] %s\n   -> %s\n", i+1, len(symlinks), symlink.Source, symlink.Destination)
+		if symlink.Action == link.RefuseAction {
+			printStatus(settings, "   refused: %s\n", symlink.Error)
+		}
+		if symlink.Action == link.ConflictAction {
+			reason := symlink.Error
+			if reason == "" {
+				reason = "destination already exists"
+			}
+			printStatus(settings, "   conflict: %s\n", reason)
+		}
+
+		answer, ok := readAnswer("Create this symlink? [y]es/[n]o/[a]ll/[q]uit/[e]dit: ")
+		if !ok {
+			printStatus(settings, "\n")
+			answer = "quit"
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+		case "n", "no":
+			symlink.Action = link.ExcludeAction
+		case "a", "all":
+			return
+		case "q", "quit":
+			quit(i)
+			return
+		case "e", "edit":
+			destination, ok := readAnswer("New destination: ")
+			if !ok {
+				printStatus(settings, "\n")
+				quit(i)
+				return
+			}
+			if destination != "" {
+				symlink.Destination = destination
+				if symlink.Action == link.RefuseAction {
+					symlink.Action = link.CreateAction
+				}
+				replanSymlinks(symlinks, settings)
+			}
+			i--
+		default:
+			i--
+		}
+	}
+}
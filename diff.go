@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+
+	"supalink/link"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+var diffMarkers = map[string]string{
+	link.CreateAction:   "+",
+	link.SkipAction:     "=",
+	link.ReplaceAction:  "~",
+	link.ConflictAction: "!",
+	link.RefuseAction:   "!",
+}
+
+// diffMarker renders the marker of an action, or returns false for actions
+// that don't show up in the diff.
+func diffMarker(action string) (string, bool) {
+	marker, ok := diffMarkers[action]
+	if !ok {
+		return "", false
+	}
+	style, _ := actionStyle(action)
+	return style.Render(marker), true
+}
+
+// printSymlinksAsDiff shows the destination tree as it is now next to the
+// same tree with the planned symlinks merged in, each marked with what the
+// run would do to it.
+func printSymlinksAsDiff(symlinks link.Plan, settings settings) {
+	destinations := make([]string, 0, len(symlinks))
+	for _, symlink := range symlinks {
+		destinations = append(destinations, symlink.Destination)
+	}
+	rootDirectory := findRootDirectoryOfAllPaths(destinations)
+	slog.Debug("Comparing against destination tree", "root", rootDirectory)
+
+	existingPaths := walkExistingPaths(settings.filesystem(), rootDirectory)
+	existing := make(map[string]bool, len(existingPaths))
+	for _, path := range existingPaths {
+		existing[path] = true
+	}
+
+	markers := make(map[string]string)
+	newDirectoryMarker, _ := diffMarker(link.CreateAction)
+	if _, err := fs.Stat(settings.filesystem(), rootDirectory); err != nil {
+		markers[rootDirectory] = newDirectoryMarker
+	}
+	afterPaths := append(make([]string, 0, len(existingPaths)+len(destinations)), existingPaths...)
+	for _, symlink := range symlinks {
+		marker, ok := diffMarker(symlink.Action)
+		if !ok {
+			continue
+		}
+		markers[symlink.Destination] = marker
+
+		if !existing[symlink.Destination] {
+			afterPaths = append(afterPaths, symlink.Destination)
+		}
+		for directory := filepath.Dir(symlink.Destination); !existing[directory] && directory != rootDirectory && directory != filepath.Dir(directory); directory = filepath.Dir(directory) {
+			markers[directory] = newDirectoryMarker
+		}
+	}
+
+	width := columnWidth(settings, 2)
+	currentTree := "(empty)"
+	if len(existingPaths) > 0 {
+		currentTree = fmt.Sprintln(createTree(existingPaths).toLipglossTree(width))
+	}
+
+	afterTree := createTree(afterPaths)
+	afterTree.mark(afterTree.value, markers)
+
+	table := table.
+		New().
+		Headers("Current", "After").
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(styles.Border).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle().Padding(0, 1)
+			if row == table.HeaderRow {
+				style = style.Inherit(styles.Header)
+			}
+
+			return style
+		}).
+		Rows([]string{currentTree, fmt.Sprintln(afterTree.toLipglossTree(width))})
+	fmt.Println(table)
+}
+
+// walkExistingPaths lists what is currently below the destination root,
+// without the root itself.
+func walkExistingPaths(fsys fs.FS, rootDirectory string) []string {
+	paths := make([]string, 0)
+	fs.WalkDir(fsys, rootDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != rootDirectory {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
+// mark sets the marker of every node whose full path has one.
+func (n *node) mark(path string, markers map[string]string) {
+	n.marker = markers[path]
+	for _, child := range n.children {
+		child.mark(filepath.Join(path, child.value), markers)
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"supalink/link"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	ExampleFlag      = "example"
+	exampleSeparator = "=>"
+)
+
+var trailingCaptureExp = regexp.MustCompile(`\$[0-9]+$`)
+
+// example is a source path and the destination it should be linked to.
+type example struct {
+	source      string
+	destination string
+	runs        []nameRun
+}
+
+// learnFromExamples synthesizes a source regex, a destination template and
+// step sizes from examples. The examples are aligned run by run: numbers of
+// the source that show up in the destination, and text that differs between
+// examples and shows up in the destination, are captured. Everything else is
+// fixed. Numbers written with another padding are padded by the template, and
+// numbers of the destination that come from none of the source are taken for
+// $STEP and $STEP_COUNT when a number of the source tells the position of the
+// file. Examples the result doesn't reproduce exactly are reported as
+// warnings.
+func learnFromExamples(values []string) (string, string, []int, []string, error) {
+	examples := make([]example, 0, len(values))
+	for _, value := range values {
+		source, destination, ok := strings.Cut(value, exampleSeparator)
+		if !ok {
+			return "", "", nil, nil, fmt.Errorf("invalid example %q (expected SOURCE%sDESTINATION)", value, exampleSeparator)
+		}
+		source, err := filepath.Abs(strings.TrimSpace(source))
+		if err != nil {
+			return "", "", nil, nil, err
+		}
+		destination, err = filepath.Abs(strings.TrimSpace(destination))
+		if err != nil {
+			return "", "", nil, nil, err
+		}
+		examples = append(examples, example{source: source, destination: destination, runs: splitRuns(filepath.Base(source))})
+	}
+
+	first := examples[0]
+	for _, e := range examples[1:] {
+		if filepath.Dir(e.source) != filepath.Dir(first.source) {
+			return "", "", nil, nil, fmt.Errorf("examples have to be in the same directory, %s and %s are not", first.source, e.source)
+		}
+		if !sameShape(e.runs, first.runs) {
+			return "", "", nil, nil, fmt.Errorf("examples have to be named alike, %s and %s are not", filepath.Base(first.source), filepath.Base(e.source))
+		}
+	}
+
+	// pieces holds the regex of every run of the source.
+	pieces := make([]string, 0, len(first.runs))
+	// captured maps every captured run to its group number.
+	captured := make(map[int]int)
+	for i, run := range first.runs {
+		values := make([]string, 0, len(examples))
+		for _, e := range examples {
+			values = append(values, e.runs[i].text)
+		}
+		varies := slices.ContainsFunc(values, func(value string) bool { return value != values[0] })
+		used := !slices.ContainsFunc(examples, func(e example) bool {
+			if run.digits {
+				return !slices.Contains(destinationNumbers(e.destination), number(e.runs[i].text))
+			}
+			return !strings.Contains(e.destination, e.runs[i].text)
+		})
+
+		switch {
+		case run.digits && used:
+			captured[i] = len(captured) + 1
+			// Numbers only have a fixed width when they are padded.
+			padded := slices.ContainsFunc(values, func(value string) bool { return len(value) > 1 && value[0] == '0' })
+			if padded {
+				pieces = append(pieces, digitsPattern(values))
+			} else {
+				pieces = append(pieces, "([0-9]+)")
+			}
+		case run.digits && varies:
+			pieces = append(pieces, "[0-9]+")
+		case varies && used:
+			captured[i] = len(captured) + 1
+			pieces = append(pieces, "(.+?)")
+		case varies:
+			pieces = append(pieces, ".+?")
+		default:
+			pieces = append(pieces, regexp.QuoteMeta(run.text))
+		}
+	}
+
+	template, steps := exampleTemplate(examples, captured)
+	if steps != nil {
+		// Every file is numbered, whatever its position.
+		pieces[steps.position] = "[0-9]+"
+	}
+	pattern := regexp.QuoteMeta(filepath.ToSlash(filepath.Dir(first.source))) + "/" + strings.Join(pieces, "")
+
+	planner, err := link.NewPlanner(pattern, template)
+	if err != nil {
+		return "", "", nil, nil, err
+	}
+	warnings := make([]string, 0)
+	for _, e := range examples {
+		matches, _ := planner.Pattern.Match(filepath.ToSlash(e.source))
+		if matches == nil {
+			warnings = append(warnings, fmt.Sprintf("the regex doesn't match %s", e.source))
+			continue
+		}
+		step, stepCount := 0, 0
+		if steps != nil {
+			step, stepCount = steps.of(number(e.runs[steps.position].text))
+		}
+		destination, err := planner.Template.Fill(matches[1:], step, stepCount)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		} else if destination != filepath.ToSlash(e.destination) {
+			warnings = append(warnings, fmt.Sprintf("%s would be linked to %s, not %s", e.source, destination, e.destination))
+		}
+	}
+
+	if steps == nil {
+		return pattern, template, nil, warnings, nil
+	}
+	return pattern, template, steps.sizes, warnings, nil
+}
+
+// shapedDestination is the destination of an example, split into runs.
+type shapedDestination struct {
+	example int
+	runs    []nameRun
+}
+
+// exampleSteps is how the files of the examples are split into steps.
+type exampleSteps struct {
+	// position is the run of the source numbering the files from 1.
+	position int
+	sizes    []int
+}
+
+// of returns the $STEP and $STEP_COUNT of the file at position.
+func (s *exampleSteps) of(position int) (int, int) {
+	for i, size := range s.sizes {
+		if position <= size || i == len(s.sizes)-1 {
+			return i + 1, position
+		}
+		position -= size
+	}
+	return 0, 0
+}
+
+// exampleTemplate turns the destination of the first example into a
+// template, by replacing the values captured from its source with their
+// group. Numbers are matched by value, and have to match in every example
+// whose destination is shaped alike, so that the more examples are given,
+// the fewer numbers are mistaken for one another. Numbers matching no capture
+// are tried as steps, see inferSteps.
+func exampleTemplate(examples []example, captured map[int]int) (string, *exampleSteps) {
+	first := examples[0]
+	// destinations holds the runs of every destination shaped like the
+	// first one, in the order of the examples.
+	destinations := make([]shapedDestination, 0, len(examples))
+	for k, e := range examples {
+		runs := splitRuns(filepath.ToSlash(e.destination))
+		if sameShape(runs, splitRuns(filepath.ToSlash(first.destination))) {
+			destinations = append(destinations, shapedDestination{example: k, runs: runs})
+		}
+	}
+
+	digits := make([]int, 0)
+	texts := make([]int, 0)
+	for i, run := range first.runs {
+		if _, ok := captured[i]; !ok {
+			continue
+		}
+		if run.digits {
+			digits = append(digits, i)
+		} else {
+			texts = append(texts, i)
+		}
+	}
+	// Longer texts go first, so a text containing another one is replaced
+	// whole.
+	slices.SortStableFunc(texts, func(a, b int) int {
+		return len(first.runs[b].text) - len(first.runs[a].text)
+	})
+
+	used := make(map[int]bool)
+	pieces := make([]string, len(destinations[0].runs))
+	unmatched := make([]int, 0)
+	for j, run := range destinations[0].runs {
+		if !run.digits {
+			text := run.text
+			for _, i := range texts {
+				text = strings.ReplaceAll(text, first.runs[i].text, fmt.Sprintf("$%d", captured[i]))
+			}
+			pieces[j] = text
+			continue
+		}
+
+		candidates := slices.DeleteFunc(slices.Clone(digits), func(i int) bool {
+			for _, d := range destinations {
+				if number(examples[d.example].runs[i].text) != number(d.runs[j].text) {
+					return true
+				}
+			}
+			return false
+		})
+		if len(candidates) == 0 {
+			pieces[j] = run.text
+			unmatched = append(unmatched, j)
+			continue
+		}
+		chosen := candidates[0]
+		for _, candidate := range candidates {
+			if !used[candidate] {
+				chosen = candidate
+				break
+			}
+		}
+		used[chosen] = true
+
+		sources := make([]string, 0, len(destinations))
+		targets := make([]string, 0, len(destinations))
+		for _, d := range destinations {
+			sources = append(sources, examples[d.example].runs[chosen].text)
+			targets = append(targets, d.runs[j].text)
+		}
+		pieces[j] = parameter(strconv.Itoa(captured[chosen]), paddingWidth(sources, targets))
+	}
+
+	steps := inferSteps(examples, destinations, captured, unmatched)
+	if steps != nil {
+		for n, j := range unmatched {
+			values := make([]string, 0, len(destinations))
+			targets := make([]string, 0, len(destinations))
+			for _, d := range destinations {
+				values = append(values, strconv.Itoa(number(d.runs[j].text)))
+				targets = append(targets, d.runs[j].text)
+			}
+			name := "STEP"
+			if n == len(unmatched)-1 {
+				name = "STEP_COUNT"
+			}
+			pieces[j] = parameter(name, paddingWidth(values, targets))
+		}
+	}
+
+	return joinTemplate(pieces), steps
+}
+
+// joinTemplate joins the pieces of a template, bracing a capture followed by
+// a digit so it isn't read as another group.
+func joinTemplate(pieces []string) string {
+	template := ""
+	for _, piece := range pieces {
+		if piece != "" && isDigit(rune(piece[0])) {
+			if loc := trailingCaptureExp.FindStringIndex(template); loc != nil {
+				template = template[:loc[0]] + "${" + template[loc[0]+1:] + "}"
+			}
+		}
+		template += piece
+	}
+	return template
+}
+
+// inferSteps works out whether the numbers of the destinations that match no
+// capture are steps: the last one being $STEP_COUNT and the others $STEP,
+// with an uncaptured number of the source giving the position of the file
+// among all of them, from 1. The size of every step before the last one seen
+// follows from the positions, spread evenly over steps no example is in. The
+// last step is given the size of the largest one. It returns nil when the
+// numbers don't fit. The last step is sized again by
+// argumentsFromExamples once the files are known.
+func inferSteps(examples []example, destinations []shapedDestination, captured map[int]int, unmatched []int) *exampleSteps {
+	if len(unmatched) < 2 {
+		return nil
+	}
+
+	type numbered struct {
+		example, step, stepCount int
+	}
+	numbers := make([]numbered, 0, len(destinations))
+	for _, d := range destinations {
+		step := number(d.runs[unmatched[0]].text)
+		for _, j := range unmatched[1 : len(unmatched)-1] {
+			if number(d.runs[j].text) != step {
+				return nil
+			}
+		}
+		stepCount := number(d.runs[unmatched[len(unmatched)-1]].text)
+		if step < 1 || stepCount < 1 {
+			return nil
+		}
+		numbers = append(numbers, numbered{example: d.example, step: step, stepCount: stepCount})
+	}
+
+	for i, run := range examples[0].runs {
+		if _, ok := captured[i]; ok || !run.digits {
+			continue
+		}
+
+		// before holds how many files come before every step seen.
+		before := map[int]int{1: 0}
+		last, longest := 0, 0
+		fits := true
+		for _, n := range numbers {
+			count := number(examples[n.example].runs[i].text) - n.stepCount
+			if previous, ok := before[n.step]; (ok && previous != count) || count < 0 {
+				fits = false
+				break
+			}
+			before[n.step] = count
+			last = max(last, n.step)
+		}
+		if !fits {
+			continue
+		}
+
+		sizes := make([]int, last)
+		for step := 1; step < last; {
+			next := step + 1
+			for _, ok := before[next]; !ok; _, ok = before[next] {
+				next++
+			}
+			// Steps no example is in share the files between the two
+			// steps around them.
+			files := before[next] - before[step]
+			for k := step; k < next; k++ {
+				sizes[k-1] = files / (next - step)
+			}
+			sizes[next-2] += files % (next - step)
+			step = next
+		}
+		for _, n := range numbers {
+			if n.step < last && n.stepCount > sizes[n.step-1] {
+				fits = false
+			}
+			if n.step == last {
+				sizes[last-1] = max(sizes[last-1], n.stepCount)
+			}
+		}
+		for _, size := range sizes[:last-1] {
+			if size < 1 {
+				fits = false
+			}
+			longest = max(longest, size)
+		}
+		if !fits || last < 2 {
+			continue
+		}
+		sizes[last-1] = max(sizes[last-1], longest)
+
+		return &exampleSteps{position: i, sizes: sizes}
+	}
+
+	return nil
+}
+
+// paddingWidth returns how many digits a parameter should be padded to for
+// every value to be written as its target: zero when they are written alike,
+// the width of the targets when they start with zeros, and one to drop the
+// zeros of the values otherwise.
+func paddingWidth(values, targets []string) int {
+	if slices.Equal(values, targets) {
+		return 0
+	}
+	width := 1
+	for _, target := range targets {
+		if len(target) > 1 && target[0] == '0' {
+			width = max(width, len(target))
+		}
+	}
+	return width
+}
+
+// parameter writes a template parameter, in braces when it is padded.
+func parameter(name string, width int) string {
+	if width == 0 {
+		return "$" + name
+	}
+	return fmt.Sprintf("${%s:%d}", name, width)
+}
+
+// fitLastStep makes the last step hold every matching file the others don't.
+// Paths read from stdin can't be counted beforehand, the guess of
+// inferSteps is kept for them.
+func fitLastStep(ctx context.Context, settings settings, pattern, template string, steps []int) error {
+	if settings.FromStdin {
+		return nil
+	}
+	planner, err := newPlanner(settings, pattern, template)
+	if err != nil {
+		return err
+	}
+	planner.FS = settings.filesystem()
+	paths, err := planner.Walk(ctx)
+	if err != nil {
+		return err
+	}
+
+	matched := 0
+	for _, path := range paths {
+		if matches, _ := planner.Pattern.Match(path); matches != nil {
+			matched++
+		}
+	}
+	before := 0
+	for _, size := range steps[:len(steps)-1] {
+		before += size
+	}
+	steps[len(steps)-1] = max(steps[len(steps)-1], matched-before)
+	return nil
+}
+
+// destinationNumbers returns the value of every run of digits in path.
+func destinationNumbers(path string) []int {
+	numbers := make([]int, 0)
+	for _, run := range splitRuns(path) {
+		if run.digits {
+			numbers = append(numbers, number(run.text))
+		}
+	}
+	return numbers
+}
+
+func number(digits string) int {
+	value, _ := strconv.Atoi(digits)
+	return value
+}
+
+func sameShape(a, b []nameRun) bool {
+	return slices.EqualFunc(a, b, func(a, b nameRun) bool {
+		return a.digits == b.digits
+	})
+}
+
+// argumentsFromExamples learns the regex and template from --example and
+// has the user confirm them, unless this is a dry run. Learned steps are used
+// unless --step was given.
+func argumentsFromExamples(cmd *cobra.Command, settings *settings) ([]string, error) {
+	values, err := cmd.Flags().GetStringArray(ExampleFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern, template, steps, warnings, err := learnFromExamples(values)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings.Steps) == 0 && len(steps) > 0 {
+		if err := fitLastStep(cmd.Context(), *settings, pattern, template, steps); err != nil {
+			return nil, err
+		}
+		settings.Steps = steps
+	}
+
+	printStatus(*settings, "Learned from the examples:\n")
+	printStatus(*settings, "  Regex     %s\n", pattern)
+	printStatus(*settings, "  Template  %s\n", template)
+	if len(steps) > 0 {
+		flags := make([]string, 0, len(steps))
+		for _, size := range steps {
+			flags = append(flags, fmt.Sprintf("--%s %d", StepFlag, size))
+		}
+		printStatus(*settings, "  Steps     %s (numbering files from 1)\n", strings.Join(flags, " "))
+	}
+	for _, warning := range warnings {
+		printStatus(*settings, "%s\n", styles.Error.Render("  ! "+warning))
+	}
+
+	if !settings.DryRun {
+		printStatus(*settings, "Use this regex and template? (y/n): ")
+		input := confirmationInput(*settings)
+		answer, err := readAnswerLine(input)
+		input.Close()
+		if err != nil || strings.ToLower(answer) != "y" {
+			printStatus(*settings, "Operation cancelled by user.\n")
+			return nil, exitWith(ExitCancelled, "operation cancelled by user")
+		}
+	}
+
+	return []string{pattern, template}, nil
+}
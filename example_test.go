@@ -0,0 +1,129 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLearnFromExamples(t *testing.T) {
+	tests := []struct {
+		name         string
+		examples     []string
+		wantPattern  string
+		wantTemplate string
+		wantSteps    []int
+		wantWarnings int
+	}{
+		{
+			name:         "single example",
+			examples:     []string{"/downloads/[Grp] Show - 05 [1080p].mkv=>/library/Show/Season 01/Show S01E05.mkv"},
+			wantPattern:  `/downloads/\[Grp\] Show - ([0-9]{2}) \[1080p\]\.mkv`,
+			wantTemplate: "/library/Show/Season 01/Show S01E$1.mkv",
+		},
+		{
+			name:         "padding",
+			examples:     []string{"/downloads/Show - 5.mkv=>/library/Show/Show E05.mkv"},
+			wantPattern:  `/downloads/Show - ([0-9]+)\.mkv`,
+			wantTemplate: "/library/Show/Show E${1:2}.mkv",
+		},
+		{
+			name:         "dropped padding",
+			examples:     []string{"/downloads/Show - 05.mkv=>/library/Show/Show E5.mkv"},
+			wantPattern:  `/downloads/Show - ([0-9]{2})\.mkv`,
+			wantTemplate: "/library/Show/Show E${1:1}.mkv",
+		},
+		{
+			name: "capture followed by a digit",
+			examples: []string{
+				"/downloads/[Alpha]01.mkv=>/library/[Alpha]2/E01.mkv",
+				"/downloads/[Beta]02.mkv=>/library/[Beta]2/E02.mkv",
+			},
+			wantPattern:  `/downloads/(.+?)([0-9]{2})\.mkv`,
+			wantTemplate: "/library/${1}2/E$2.mkv",
+		},
+		{
+			name:         "steps",
+			examples:     []string{"/downloads/Video - 03.mkv=>/library/Video/Season 2/Video S2E1.mkv"},
+			wantPattern:  `/downloads/Video - [0-9]+\.mkv`,
+			wantTemplate: "/library/Video/Season $STEP/Video S$STEPE$STEP_COUNT.mkv",
+			wantSteps:    []int{2, 2},
+		},
+		{
+			name:         "padded steps",
+			examples:     []string{"/downloads/Video - 03.mkv=>/library/Video/Season 02/Video S02E01.mkv"},
+			wantPattern:  `/downloads/Video - [0-9]+\.mkv`,
+			wantTemplate: "/library/Video/Season ${STEP:2}/Video S${STEP:2}E${STEP_COUNT:2}.mkv",
+			wantSteps:    []int{2, 2},
+		},
+		{
+			name: "steps from several examples",
+			examples: []string{
+				"/downloads/Video - 03.mkv=>/library/Video/Season 2/Video S2E1.mkv",
+				"/downloads/Video - 07.mkv=>/library/Video/Season 3/Video S3E2.mkv",
+			},
+			wantPattern:  `/downloads/Video - [0-9]+\.mkv`,
+			wantTemplate: "/library/Video/Season $STEP/Video S$STEPE$STEP_COUNT.mkv",
+			wantSteps:    []int{2, 3, 3},
+		},
+		{
+			name: "examples the result doesn't reproduce",
+			examples: []string{
+				"/downloads/Alpha - 01.mkv=>/library/X/E01.mkv",
+				"/downloads/Beta - 02.mkv=>/library/Y/E02.mkv",
+			},
+			wantPattern:  `/downloads/.+?([0-9]{2})\.mkv`,
+			wantTemplate: "/library/X/E$1.mkv",
+			wantWarnings: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The result has to be the same however the examples are
+			// iterated.
+			for range 10 {
+				pattern, template, steps, warnings, err := learnFromExamples(test.examples)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pattern != test.wantPattern {
+					t.Errorf("got pattern %s, want %s", pattern, test.wantPattern)
+				}
+				if template != test.wantTemplate {
+					t.Errorf("got template %s, want %s", template, test.wantTemplate)
+				}
+				if !slices.Equal(steps, test.wantSteps) {
+					t.Errorf("got steps %v, want %v", steps, test.wantSteps)
+				}
+				if len(warnings) != test.wantWarnings {
+					t.Errorf("got warnings %q, want %d", warnings, test.wantWarnings)
+				}
+				if t.Failed() {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestLearnFromExamplesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		examples []string
+		want     string
+	}{
+		{"missing separator", []string{"/downloads/Show - 01.mkv"}, "invalid example"},
+		{"other directories", []string{"/a/Show - 01.mkv=>/library/E01.mkv", "/b/Show - 02.mkv=>/library/E02.mkv"}, "same directory"},
+		{"other names", []string{"/a/Show - 01.mkv=>/library/E01.mkv", "/a/Show.mkv=>/library/E02.mkv"}, "named alike"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, _, _, err := learnFromExamples(test.examples)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want an error about %q", err, test.want)
+			}
+		})
+	}
+}
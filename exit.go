@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"supalink/link"
+)
+
+// Exit codes returned by supalink, so scripts and systemd units can tell how
+// a run went without parsing its output.
+const (
+	ExitSuccess        = 0
+	ExitError          = 1
+	ExitNoMatches      = 2
+	ExitPartialFailure = 3
+	ExitTotalFailure   = 4
+	ExitConflicts      = 5
+	ExitCancelled      = 6
+)
+
+// exitCodeError ends a run with a specific exit code. Its message has already
+// been shown to the user through the results, so it isn't printed again.
+type exitCodeError struct {
+	code    int
+	message string
+}
+
+func (e *exitCodeError) Error() string {
+	return e.message
+}
+
+func exitWith(code int, message string) error {
+	return &exitCodeError{code: code, message: message}
+}
+
+// execute runs the root command and turns its outcome into an exit code.
+func execute() int {
+	err := rootCmd.Execute()
+	if err == nil {
+		return ExitSuccess
+	}
+
+	var exitCode *exitCodeError
+	if errors.As(err, &exitCode) {
+		return exitCode.code
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return ExitError
+}
+
+// outcomeOf tells how a run went from the results of its symlinks. Symlinks
+// already in place count as successes, and a run where nothing was created
+// only because of conflicts or refused destinations is reported as blocked
+// by them.
+func outcomeOf(symlinks link.Plan) error {
+	counts := make(map[string]int)
+	conflicts := 0
+	for _, symlink := range symlinks {
+		counts[symlink.Result]++
+		if symlink.Result == link.FailedResult && (symlink.Action == link.ConflictAction || symlink.Action == link.RefuseAction) {
+			conflicts++
+		}
+	}
+
+	succeeded := counts[link.CreatedResult] + counts[link.ReplacedResult] + counts[link.SkippedResult]
+	failed := counts[link.FailedResult]
+
+	switch {
+	case failed == 0 && succeeded == 0 && counts[link.CancelledResult] > 0:
+		return exitWith(ExitCancelled, "operation cancelled by user")
+	case failed == 0:
+		return nil
+	case succeeded > 0:
+		return exitWith(ExitPartialFailure, fmt.Sprintf("%d of %d symlinks failed", failed, len(symlinks)))
+	case conflicts == failed:
+		return exitWith(ExitConflicts, "every symlink was blocked by a conflict or refused")
+	default:
+		return exitWith(ExitTotalFailure, "every symlink failed")
+	}
+}
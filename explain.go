@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"supalink/link"
+
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <source path regex> <destination path template>",
+	Short: "Describe how the regex, template and steps of a command are interpreted, and warn about likely mistakes",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := getSettings(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		planner, err := newPlanner(settings, args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		groups, err := captureGroups(planner.Pattern, settings.RegexEngine)
+		if err != nil {
+			return err
+		}
+		tokens := planner.Template.Tokens()
+
+		printExplainHeading("Source")
+		fmt.Printf("  Walks   %s\n", planner.RootDirectory())
+		if planner.RootDirectory() == "." {
+			fmt.Println("          The regex has no plain leading directory, so the current directory is walked")
+		}
+		fmt.Printf("  Regex   %s\n", planner.Pattern.String())
+		if planner.Pattern.String() != args[0] {
+			fmt.Println("          $ was appended, so the regex has to match up to the end of the path")
+		}
+		if !strings.HasPrefix(args[0], "^") {
+			fmt.Println("          The regex isn't anchored at the start, so it can begin anywhere in the path")
+		}
+
+		printExplainHeading("Capture groups")
+		if len(groups) == 0 {
+			fmt.Println("  None")
+		}
+		for _, group := range groups {
+			name := ""
+			if group.Name != "" {
+				name = fmt.Sprintf(" (%s)", group.Name)
+			}
+			fmt.Printf("  $%d%s  %s\n", group.Number, name, group.Text)
+		}
+
+		printExplainHeading("Destination")
+		fmt.Printf("  Root    %s\n", planner.Template.Root())
+		for _, token := range tokens {
+			switch token.Kind {
+			case link.TextToken:
+				fmt.Printf("  %-10s %q\n", token.Kind, token.Text)
+			case link.CaptureToken:
+				fmt.Printf("  %-10s %s, group %d%s\n", token.Kind, token.Text, token.Index, explainWidth(token))
+			default:
+				fmt.Printf("  %-10s %s%s\n", token.Kind, token.Text, explainWidth(token))
+			}
+		}
+
+		printExplainHeading("Steps")
+		if len(settings.Steps) == 0 {
+			fmt.Println("  None")
+		}
+		total := 0
+		for i, size := range settings.Steps {
+			fmt.Printf("  Step %d holds %d items\n", i+1, size)
+			total += size
+		}
+		if len(settings.Steps) > 0 {
+			fmt.Printf("  At most %d items can be numbered\n", total)
+		}
+
+		warnings := explainWarnings(groups, tokens, settings.Steps)
+		if len(warnings) > 0 {
+			printExplainHeading("Warnings")
+			for _, warning := range warnings {
+				fmt.Println(styles.Error.Render("  ! " + warning))
+			}
+		}
+
+		return nil
+	},
+}
+
+func printExplainHeading(heading string) {
+	fmt.Println(styles.Header.Render(heading))
+}
+
+func explainWidth(token link.TemplateToken) string {
+	if token.Width == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", numbers padded to %d digits", token.Width)
+}
+
+// captureGroup is a capture group of the source pattern.
+type captureGroup struct {
+	Number int
+	Name   string
+	// Text is the sub-expression of the group, only known with the re2
+	// engine.
+	Text string
+}
+
+// captureGroups returns the capture groups of pattern, in the order of their
+// numbers.
+func captureGroups(pattern link.Pattern, engine string) ([]captureGroup, error) {
+	names := pattern.SubexpNames()
+	groups := make([]captureGroup, 0, len(names))
+	for number, name := range names[1:] {
+		groups = append(groups, captureGroup{Number: number + 1, Name: name, Text: "(sub-expression not shown by the pcre engine)"})
+	}
+	if engine == link.PCREEngine {
+		return groups, nil
+	}
+
+	parsed, err := syntax.Parse(pattern.String(), syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source pattern: %w", err)
+	}
+	var walk func(*syntax.Regexp)
+	walk = func(exp *syntax.Regexp) {
+		if exp.Op == syntax.OpCapture && exp.Cap <= len(groups) {
+			groups[exp.Cap-1].Text = exp.Sub[0].String()
+		}
+		for _, sub := range exp.Sub {
+			walk(sub)
+		}
+	}
+	walk(parsed)
+	return groups, nil
+}
+
+// explainWarnings flags what a command most likely doesn't mean.
+func explainWarnings(groups []captureGroup, tokens []link.TemplateToken, steps []int) []string {
+	warnings := make([]string, 0)
+
+	used := make(map[int]bool)
+	usesSteps := false
+	for _, token := range tokens {
+		switch token.Kind {
+		case link.CaptureToken:
+			used[token.Index] = true
+			// Braced captures can't be misread.
+			digits := strings.TrimPrefix(token.Text, "$")
+			if strings.HasPrefix(digits, "{") {
+				digits = ""
+			}
+			if token.Index < 1 {
+				warnings = append(warnings, fmt.Sprintf("%s doesn't refer to any group, groups are numbered from 1", token.Text))
+			} else if token.Index > len(groups) {
+				warnings = append(warnings, fmt.Sprintf("%s refers to group %d, but the regex only has %d groups", token.Text, token.Index, len(groups)))
+			}
+			if len(digits) > 1 {
+				first, _ := strconv.Atoi(digits[:1])
+				warnings = append(warnings, fmt.Sprintf("%s is read as group %d, not as $%d followed by %q (write ${%d}%s for that)", token.Text, token.Index, first, digits[1:], first, digits[1:]))
+			}
+		case link.StepToken, link.StepCountToken:
+			usesSteps = true
+		case link.TextToken:
+			if strings.Contains(token.Text, "$") {
+				warnings = append(warnings, fmt.Sprintf("%q contains a $ that isn't a parameter and is kept as is", token.Text))
+			}
+		}
+	}
+
+	for _, group := range groups {
+		if !used[group.Number] {
+			warnings = append(warnings, fmt.Sprintf("group $%d is captured but never used in the template", group.Number))
+		}
+	}
+
+	if usesSteps && len(steps) == 0 {
+		warnings = append(warnings, fmt.Sprintf("the template uses steps, but no --%s is given, so $STEP and $STEP_COUNT are kept as is", StepFlag))
+	}
+	if !usesSteps && len(steps) > 0 {
+		warnings = append(warnings, fmt.Sprintf("--%s is given, but the template uses neither $STEP nor $STEP_COUNT", StepFlag))
+	}
+
+	return warnings
+}
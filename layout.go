@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	defaultTerminalWidth = 100
+	truncationMarker     = "(...)"
+	// Each table column is padded by one space on both sides, and the table
+	// has a border before, between and after the columns.
+	tableColumnOverhead = 3
+	// Every level of a lipgloss tree is indented by a 4-cell branch.
+	treeIndentWidth = 4
+)
+
+// terminalWidth returns the width of the terminal stdout is attached to,
+// falling back to $COLUMNS and then to a sensible default.
+func terminalWidth() int {
+	if width, _, err := term.GetSize(os.Stdout.Fd()); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+// columnWidth returns how many cells each column of a table with the given
+// number of columns can use. Zero means values should not be truncated.
+func columnWidth(settings settings, columns int) int {
+	if settings.NoTruncate {
+		return 0
+	}
+	return max((terminalWidth()-1)/columns-tableColumnOverhead, len(truncationMarker)+1)
+}
+
+// truncatePath shortens value to at most width terminal cells, keeping its
+// extension visible. It counts display width rather than bytes, so wide and
+// multi-byte characters are never cut in half. A width of zero or less
+// disables truncation.
+func truncatePath(value string, width int) string {
+	if width <= 0 || runewidth.StringWidth(value) <= width {
+		return value
+	}
+
+	extension := path.Ext(value)
+	suffix := truncationMarker + extension
+	if runewidth.StringWidth(suffix) >= width {
+		suffix = truncationMarker
+	}
+
+	head := value
+	if suffix != truncationMarker {
+		head = value[:len(value)-len(extension)]
+	}
+
+	return runewidth.Truncate(head, max(width-runewidth.StringWidth(suffix), 0), "") + suffix
+}
+
+// truncateText shortens free text, such as error messages, to at most width
+// terminal cells. A width of zero or less disables truncation.
+func truncateText(value string, width int) string {
+	if width <= 0 {
+		return value
+	}
+	return runewidth.Truncate(value, width, truncationMarker)
+}
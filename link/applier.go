@@ -0,0 +1,200 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Applier creates the symlinks of a plan.
+type Applier struct {
+	// Atomic stops at the first item that fails and rolls back every change
+	// made so far.
+	Atomic bool
+	// Linker is where symlinks are created. OS when nil.
+	Linker Linker
+}
+
+// Apply creates every planned symlink and records the result of each item.
+// Items already cancelled are left alone. When the context is cancelled, the
+// remaining items are cancelled too. Failed items only make Apply return an
+// error when it is atomic, in which case the error is a *RollbackError.
+func (a *Applier) Apply(ctx context.Context, plan Plan) error {
+	linker := a.linker()
+
+	var tx *transaction
+	if a.Atomic {
+		tx = &transaction{linker: linker}
+	}
+
+	for i := range plan {
+		item := &plan[i]
+
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(plan); j++ {
+				if plan[j].Result == "" {
+					plan[j].Result = CancelledResult
+				}
+			}
+			if tx != nil {
+				return tx.rollback(plan, -1, err)
+			}
+			return err
+		}
+
+		if item.Result == CancelledResult {
+			continue
+		}
+
+		switch item.Action {
+		case SkipAction, ExcludeAction:
+			item.Result = SkippedResult
+			continue
+		case ConflictAction, RefuseAction:
+			item.Result = FailedResult
+			if item.Error == "" {
+				item.Error = "destination already exists"
+			}
+			if tx != nil {
+				return tx.rollback(plan, i, nil)
+			}
+			continue
+		}
+
+		if item.Action == ReplaceAction {
+			previous, _ := linker.Readlink(item.Destination)
+			if err := linker.Remove(item.Destination); err != nil {
+				item.Result = FailedResult
+				item.Error = err.Error()
+				if tx != nil {
+					return tx.rollback(plan, i, nil)
+				}
+				continue
+			}
+			if tx != nil {
+				tx.link(i, previous)
+			}
+		}
+
+		created, _ := mkdirAll(linker, filepath.Dir(item.Destination))
+		if tx != nil {
+			tx.directories = append(tx.directories, created...)
+		}
+		err := linker.Symlink(item.Source, item.Destination)
+		if err != nil {
+			item.Result = FailedResult
+			item.Error = err.Error()
+			if tx != nil {
+				return tx.rollback(plan, i, nil)
+			}
+		} else if item.Action == ReplaceAction {
+			item.Result = ReplacedResult
+		} else {
+			item.Result = CreatedResult
+			if tx != nil {
+				tx.link(i, "")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *Applier) linker() Linker {
+	if a.Linker == nil {
+		return OS
+	}
+	return a.Linker
+}
+
+// transaction records every change made by an atomic Apply, so they can all
+// be undone when one of them fails.
+type transaction struct {
+	linker      Linker
+	directories []string
+	links       []transactionLink
+}
+
+// transactionLink is a symlink created by the transaction. previous is the
+// target of the symlink it replaced, if any.
+type transactionLink struct {
+	index    int
+	previous string
+}
+
+func (t *transaction) link(index int, previous string) {
+	t.links = append(t.links, transactionLink{index: index, previous: previous})
+}
+
+// RollbackError is returned when an atomic Apply was rolled back. It carries
+// the error that started the rollback, how many changes were undone and the
+// paths that could not be restored.
+type RollbackError struct {
+	Err       error
+	Restored  int
+	Leftovers []string
+}
+
+func (e *RollbackError) Error() string {
+	message := fmt.Sprintf("%v, rolled back %d changes", e.Err, e.Restored)
+	if len(e.Leftovers) > 0 {
+		message += fmt.Sprintf("\ncould not roll back %d changes:\n  %s", len(e.Leftovers), strings.Join(e.Leftovers, "\n  "))
+	}
+	return message
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// rollback undoes every change of the transaction, newest first, after the
+// item at index failed. Items that were not attempted yet are cancelled. An
+// index of -1 means no item failed and cause tells why the run stopped.
+func (t *transaction) rollback(plan Plan, failed int, cause error) *RollbackError {
+	rollbackErr := &RollbackError{Err: cause}
+	if failed >= 0 {
+		item := plan[failed]
+		rollbackErr.Err = fmt.Errorf("failed to create symlink %s -> %s: %s", item.Source, item.Destination, item.Error)
+		for i := failed + 1; i < len(plan); i++ {
+			if plan[i].Result == "" {
+				plan[i].Result = CancelledResult
+			}
+		}
+	}
+
+	for i := len(t.links) - 1; i >= 0; i-- {
+		link := t.links[i]
+		item := &plan[link.index]
+
+		err := t.linker.Remove(item.Destination)
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			err = nil
+			if link.previous != "" {
+				err = t.linker.Symlink(link.previous, item.Destination)
+			}
+		}
+		if err != nil {
+			rollbackErr.Leftovers = append(rollbackErr.Leftovers, fmt.Sprintf("%s: %v", item.Destination, err))
+			continue
+		}
+
+		rollbackErr.Restored++
+		if link.index != failed {
+			item.Result = RolledBackResult
+		}
+	}
+
+	for i := len(t.directories) - 1; i >= 0; i-- {
+		directory := t.directories[i]
+		if err := t.linker.Remove(directory); err != nil {
+			rollbackErr.Leftovers = append(rollbackErr.Leftovers, fmt.Sprintf("%s: %v", directory, err))
+			continue
+		}
+		rollbackErr.Restored++
+	}
+
+	return rollbackErr
+}
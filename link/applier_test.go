@@ -0,0 +1,142 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestApplierApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		atomic  bool
+		replace bool
+		// broken makes the destination of the second item unusable.
+		broken      bool
+		wantResults []string
+		wantLinks   map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        "creates every symlink",
+			wantResults: []string{CreatedResult, CreatedResult, CreatedResult},
+			wantLinks:   map[string]string{"/library/Show/1.mkv": "/downloads/1.mkv", "/library/Show/3.mkv": "/downloads/3.mkv"},
+		},
+		{
+			name:        "keeps going after a failure",
+			broken:      true,
+			wantResults: []string{CreatedResult, FailedResult, CreatedResult},
+			wantLinks:   map[string]string{"/library/Show/1.mkv": "/downloads/1.mkv", "/library/Show/3.mkv": "/downloads/3.mkv"},
+		},
+		{
+			name:        "rolls back after a failure when atomic",
+			atomic:      true,
+			broken:      true,
+			wantResults: []string{RolledBackResult, FailedResult, CancelledResult},
+			wantLinks:   map[string]string{"/library/Show/1.mkv": "", "/library/Show/3.mkv": ""},
+			wantErr:     true,
+		},
+		{
+			name:        "skips and replaces existing symlinks",
+			files:       []string{"/library/Show/1.mkv -> /downloads/1.mkv", "/library/Show/3.mkv -> /elsewhere/3.mkv"},
+			replace:     true,
+			wantResults: []string{SkippedResult, CreatedResult, ReplacedResult},
+			wantLinks:   map[string]string{"/library/Show/1.mkv": "/downloads/1.mkv", "/library/Show/3.mkv": "/downloads/3.mkv"},
+		},
+		{
+			name:        "restores replaced symlinks when rolling back",
+			files:       []string{"/library/Show/1.mkv -> /elsewhere/1.mkv"},
+			atomic:      true,
+			replace:     true,
+			broken:      true,
+			wantResults: []string{RolledBackResult, FailedResult, CancelledResult},
+			wantLinks:   map[string]string{"/library/Show/1.mkv": "/elsewhere/1.mkv"},
+			wantErr:     true,
+		},
+		{
+			name:        "fails conflicts",
+			files:       []string{"/library/Show/3.mkv"},
+			wantResults: []string{CreatedResult, CreatedResult, FailedResult},
+			wantLinks:   map[string]string{"/library/Show/1.mkv": "/downloads/1.mkv"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := append([]string{"/downloads/1.mkv", "/downloads/2.mkv", "/downloads/3.mkv"}, test.files...)
+			if test.broken {
+				// A file where a directory should be.
+				files = append(files, "/library/Broken")
+			}
+			m := newTestFS(t, files...)
+
+			second := "/library/Show/2.mkv"
+			if test.broken {
+				second = "/library/Broken/2.mkv"
+			}
+			plan := Plan{
+				{Source: "/downloads/1.mkv", Destination: "/library/Show/1.mkv"},
+				{Source: "/downloads/2.mkv", Destination: second},
+				{Source: "/downloads/3.mkv", Destination: "/library/Show/3.mkv"},
+			}
+			plan.Replan(m, test.replace)
+
+			err := (&Applier{Atomic: test.atomic, Linker: m}).Apply(context.Background(), plan)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, test.wantErr)
+			}
+			var rollbackErr *RollbackError
+			if err != nil && !errors.As(err, &rollbackErr) {
+				t.Errorf("got %T, want a *RollbackError", err)
+			}
+
+			for i, result := range test.wantResults {
+				if plan[i].Result != result {
+					t.Errorf("item %d: got %s, want %s (%s)", i, plan[i].Result, result, plan[i].Error)
+				}
+			}
+			for destination, source := range test.wantLinks {
+				target, err := m.Readlink(destination)
+				if source == "" && err == nil {
+					t.Errorf("%s still links to %s", destination, target)
+				}
+				if source != "" && target != source {
+					t.Errorf("%s links to %q, want %s", destination, target, source)
+				}
+			}
+		})
+	}
+}
+
+func TestApplierApplyRollsBackDirectories(t *testing.T) {
+	m := newTestFS(t, "/downloads/1.mkv", "/downloads/2.mkv", "/library/Broken")
+	plan := Plan{
+		{Source: "/downloads/1.mkv", Destination: "/library/Show/Season 1/1.mkv"},
+		{Source: "/downloads/2.mkv", Destination: "/library/Broken/2.mkv"},
+	}
+	plan.Replan(m, false)
+
+	err := (&Applier{Atomic: true, Linker: m}).Apply(context.Background(), plan)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if _, err := m.Lstat("/library/Show"); err == nil {
+		t.Error("/library/Show was left behind")
+	}
+}
+
+func TestApplierApplyCancelled(t *testing.T) {
+	m := newTestFS(t, "/downloads/1.mkv")
+	plan := Plan{{Source: "/downloads/1.mkv", Destination: "/library/1.mkv", Action: CreateAction}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := (&Applier{Linker: m}).Apply(ctx, plan)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if plan[0].Result != CancelledResult {
+		t.Errorf("got %s, want %s", plan[0].Result, CancelledResult)
+	}
+}
@@ -0,0 +1,123 @@
+package link
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Linker makes the changes an Applier needs in the filesystem symlinks are
+// created in. Paths are given as they appear in the plan.
+type Linker interface {
+	Lstat(path string) (fs.FileInfo, error)
+	Readlink(path string) (string, error)
+	Mkdir(path string) error
+	Symlink(target, path string) error
+	Link(target, path string) error
+	Remove(path string) error
+}
+
+// Swapper is a Linker that can also move whole trees around, which staged
+// applies need.
+type Swapper interface {
+	Linker
+	Rename(oldPath, newPath string) error
+	// Exchange swaps two paths, ideally atomically.
+	Exchange(a, b string) error
+	RemoveAll(path string) error
+}
+
+// OS is the operating system's filesystem. It can be walked by a Planner and
+// changed by an Applier, and is what they use when none is set. Unlike
+// os.DirFS, it takes OS paths as names, so absolute and relative source paths
+// both work.
+var OS = osFilesystem{}
+
+type osFilesystem struct{}
+
+func (osFilesystem) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFilesystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFilesystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFilesystem) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+func (osFilesystem) Readlink(path string) (string, error) {
+	return os.Readlink(path)
+}
+
+func (osFilesystem) Mkdir(path string) error {
+	return os.Mkdir(path, os.ModePerm)
+}
+
+func (osFilesystem) Symlink(target, path string) error {
+	return os.Symlink(target, path)
+}
+
+func (osFilesystem) Link(target, path string) error {
+	return os.Link(target, path)
+}
+
+func (osFilesystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (osFilesystem) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (osFilesystem) Exchange(a, b string) error {
+	return exchange(a, b)
+}
+
+func (osFilesystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+// mkdirAll creates directory and its missing parents, like os.MkdirAll, and
+// returns the directories it created, parents first.
+func mkdirAll(linker Linker, directory string) ([]string, error) {
+	missing := make([]string, 0)
+	for current := directory; ; current = filepath.Dir(current) {
+		info, err := linker.Lstat(current)
+		if err == nil {
+			// Symlinks are followed by the OS, so a symlink to a directory
+			// counts as one.
+			if !info.IsDir() && info.Mode()&fs.ModeSymlink == 0 {
+				return nil, &fs.PathError{Op: "mkdir", Path: current, Err: errNotDirectory}
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		missing = append(missing, current)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	created := make([]string, 0, len(missing))
+	for i := len(missing) - 1; i >= 0; i-- {
+		err := linker.Mkdir(missing[i])
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return created, err
+		}
+		created = append(created, missing[i])
+	}
+
+	return created, nil
+}
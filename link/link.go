@@ -0,0 +1,139 @@
+// Package link plans and creates the symlinks supalink is about. A Planner
+// matches source paths against a pattern and fills a destination Template for
+// each of them, giving a Plan. An Applier then creates the symlinks of the
+// Plan, optionally all or nothing, or in a staging directory swapped into
+// place once complete.
+//
+// Nothing in this package prints. Problems are returned as errors, and the
+// outcome of every symlink is recorded in its PlanItem.
+package link
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// Actions decided while planning.
+const (
+	CreateAction   = "create"
+	SkipAction     = "skip"
+	ReplaceAction  = "replace"
+	ConflictAction = "conflict"
+	ExcludeAction  = "exclude"
+	// RefuseAction is for unsafe destinations, which are never created.
+	// The reason is in the Error of the item.
+	RefuseAction = "refuse"
+)
+
+// Results recorded while applying.
+const (
+	CreatedResult    = "created"
+	SkippedResult    = "skipped"
+	ReplacedResult   = "replaced"
+	FailedResult     = "failed"
+	DryRunResult     = "dry-run"
+	CancelledResult  = "cancelled"
+	RolledBackResult = "rolled-back"
+)
+
+// PlanItem is a single planned symlink. Step and StepCount are zero when the
+// plan doesn't use steps, and Result is empty until the item is applied.
+type PlanItem struct {
+	Source      string
+	Destination string
+	Captures    []string
+	Step        int
+	StepCount   int
+	Action      string
+	Result      string
+	Error       string
+}
+
+// Plan is every symlink of a run, in the order they are created.
+type Plan []PlanItem
+
+// Action decides what applying a symlink from source to destination will do,
+// based on what is currently at the destination. Symlinks pointing somewhere
+// else are only replaced when replace is set, anything else in the way is a
+// conflict.
+func Action(linker Linker, source, destination string, replace bool) string {
+	if IsLinkedTo(linker, destination, source) {
+		return SkipAction
+	}
+	info, err := linker.Lstat(destination)
+	if err != nil {
+		return CreateAction
+	}
+	if info.Mode()&fs.ModeSymlink != 0 && replace {
+		return ReplaceAction
+	}
+	return ConflictAction
+}
+
+// IsLinkedTo reports whether destination is a symlink pointing at source.
+func IsLinkedTo(linker Linker, destination, source string) bool {
+	target, err := linker.Readlink(destination)
+	return err == nil && target == source
+}
+
+// MarkCollisions turns every item whose destination is shared with another
+// item of the plan into a conflict, since only one of them could win.
+// Excluded and refused items don't take part.
+func (p Plan) MarkCollisions() {
+	sources := make(map[string][]string)
+	for _, item := range p {
+		if item.Action == ExcludeAction || item.Action == RefuseAction {
+			continue
+		}
+		sources[item.Destination] = append(sources[item.Destination], item.Source)
+	}
+
+	for i := range p {
+		if p[i].Action == ExcludeAction || p[i].Action == RefuseAction {
+			continue
+		}
+		if others := sources[p[i].Destination]; len(others) > 1 {
+			p[i].Action = ConflictAction
+			p[i].Error = fmt.Sprintf("destination is shared by %d sources", len(others))
+		}
+	}
+}
+
+// Replan plans every item that isn't excluded or refused again, after
+// destinations were edited or items were turned back on. Edited items that
+// were refused have to be given another action first.
+func (p Plan) Replan(linker Linker, replace bool) {
+	for i := range p {
+		if p[i].Action == ExcludeAction || p[i].Action == RefuseAction {
+			continue
+		}
+		p[i].Action = Action(linker, p[i].Source, p[i].Destination, replace)
+		p[i].Error = ""
+	}
+
+	p.MarkCollisions()
+}
+
+// Confine refuses every item that isn't excluded and whose destination is
+// outside of roots. See CheckRoots. Without roots, nothing is refused.
+func (p Plan) Confine(roots []string) {
+	if len(roots) == 0 {
+		return
+	}
+	for i := range p {
+		if p[i].Action == ExcludeAction || p[i].Action == RefuseAction {
+			continue
+		}
+		if err := CheckRoots(roots, p[i].Destination); err != nil {
+			p[i].Action = RefuseAction
+			p[i].Error = err.Error()
+		}
+	}
+}
+
+// SetResults gives every item the same result.
+func (p Plan) SetResults(result string) {
+	for i := range p {
+		p[i].Result = result
+	}
+}
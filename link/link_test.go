@@ -0,0 +1,72 @@
+package link
+
+import "testing"
+
+func TestPlanMarkCollisions(t *testing.T) {
+	plan := Plan{
+		{Source: "/a", Destination: "/library/1.mkv", Action: CreateAction},
+		{Source: "/b", Destination: "/library/1.mkv", Action: CreateAction},
+		{Source: "/c", Destination: "/library/1.mkv", Action: ExcludeAction},
+		{Source: "/d", Destination: "/library/2.mkv", Action: CreateAction},
+		{Source: "/e", Destination: "/library/2.mkv", Action: RefuseAction},
+	}
+	plan.MarkCollisions()
+
+	want := []string{ConflictAction, ConflictAction, ExcludeAction, CreateAction, RefuseAction}
+	for i, action := range want {
+		if plan[i].Action != action {
+			t.Errorf("item %d: got %s, want %s", i, plan[i].Action, action)
+		}
+	}
+	if plan[0].Error != "destination is shared by 2 sources" {
+		t.Errorf("got error %q", plan[0].Error)
+	}
+}
+
+func TestPlanReplan(t *testing.T) {
+	m := newTestFS(t, "/downloads/1.mkv", "/library/taken.mkv")
+	plan := Plan{
+		{Source: "/downloads/1.mkv", Destination: "/library/taken.mkv", Action: CreateAction},
+		{Source: "/downloads/1.mkv", Destination: "/library/new.mkv", Action: ExcludeAction},
+		{Source: "/downloads/1.mkv", Destination: "/library/new.mkv", Action: RefuseAction, Error: "refused"},
+	}
+	plan.Replan(m, false)
+
+	want := []string{ConflictAction, ExcludeAction, RefuseAction}
+	for i, action := range want {
+		if plan[i].Action != action {
+			t.Errorf("item %d: got %s, want %s", i, plan[i].Action, action)
+		}
+	}
+	if plan[2].Error != "refused" {
+		t.Errorf("refused item lost its error: %q", plan[2].Error)
+	}
+}
+
+func TestPlanConfine(t *testing.T) {
+	tests := []struct {
+		name  string
+		roots []string
+		want  []string
+	}{
+		{"refuses destinations outside", []string{"/library"}, []string{CreateAction, RefuseAction, ExcludeAction}},
+		{"refuses nothing without roots", nil, []string{CreateAction, CreateAction, ExcludeAction}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := Plan{
+				{Destination: "/library/1.mkv", Action: CreateAction},
+				{Destination: "/library/../etc/1.mkv", Action: CreateAction},
+				{Destination: "/etc/2.mkv", Action: ExcludeAction},
+			}
+			plan.Confine(test.roots)
+
+			for i, action := range test.want {
+				if plan[i].Action != action {
+					t.Errorf("item %d: got %s, want %s", i, plan[i].Action, action)
+				}
+			}
+		})
+	}
+}
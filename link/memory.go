@@ -0,0 +1,532 @@
+package link
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSymlinkHops is how many symlinks MemoryFS follows before giving up on a
+// path, like the ELOOP limit of the OS.
+const maxSymlinkHops = 40
+
+var (
+	errTooManyLinks      = errors.New("too many levels of symbolic links")
+	errNotDirectory      = errors.New("not a directory")
+	errDirectoryNotEmpty = errors.New("directory not empty")
+	errIsDirectory       = errors.New("is a directory")
+)
+
+// MemoryFS is a filesystem held in memory. It can be walked by a Planner and
+// changed by an Applier like OS, which makes it possible to simulate whole
+// runs without touching the disk. Paths use forward slashes, and a leading
+// slash is optional: "/library/a" and "library/a" are the same file.
+type MemoryFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memoryNode
+	// children indexes the nodes by the directory holding them, so listing
+	// a directory doesn't go through every node.
+	children map[string]map[string]bool
+	now      func() time.Time
+}
+
+type memoryNode struct {
+	mode    fs.FileMode
+	data    []byte
+	size    int64
+	target  string
+	modTime time.Time
+}
+
+// NewMemoryFS returns an empty MemoryFS, holding only its root directory.
+func NewMemoryFS() *MemoryFS {
+	m := &MemoryFS{nodes: make(map[string]*memoryNode), children: make(map[string]map[string]bool), now: time.Now}
+	m.put(".", &memoryNode{mode: fs.ModeDir | 0o755, modTime: m.now()})
+	return m
+}
+
+// WriteFile creates or replaces a regular file, creating its parent
+// directories as needed.
+func (m *MemoryFS) WriteFile(name string, data []byte) error {
+	if err := m.MkdirAll(path.Dir(memoryPath(name))); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = memoryPath(name)
+	if node, ok := m.nodes[name]; ok && node.mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrExist}
+	}
+	m.put(name, &memoryNode{mode: 0o644, data: slices.Clone(data), size: int64(len(data)), modTime: m.now()})
+	return nil
+}
+
+// MkdirAll creates a directory and its missing parents, like os.MkdirAll.
+func (m *MemoryFS) MkdirAll(name string) error {
+	_, err := mkdirAll(m, memoryPath(name))
+	return err
+}
+
+// Truncate changes the size of a file, following symlinks. Growing a file
+// doesn't hold the new bytes in memory, they read as zeros.
+func (m *MemoryFS) Truncate(name string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.resolve(name)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		return &fs.PathError{Op: "truncate", Path: name, Err: errIsDirectory}
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrInvalid}
+	}
+	if size < int64(len(node.data)) {
+		node.data = node.data[:size]
+	}
+	node.size = size
+	return nil
+}
+
+// Chtimes changes the modification time of name, following symlinks. MemoryFS
+// doesn't keep access times, so atime is ignored.
+func (m *MemoryFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.resolve(name)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	node.modTime = mtime
+	return nil
+}
+
+// memoryPath turns any path into the key it is stored under.
+func memoryPath(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if name == "/" {
+		return "."
+	}
+	return name[1:]
+}
+
+// resolve follows symlinks in every component of name, the way the OS does
+// when opening a path.
+func (m *MemoryFS) resolve(name string) (string, *memoryNode, error) {
+	name = memoryPath(name)
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		resolved, node, err := m.resolveParent(name)
+		if err != nil {
+			return "", nil, err
+		}
+		if node == nil || node.mode&fs.ModeSymlink == 0 {
+			return resolved, node, nil
+		}
+		name = m.linkTarget(resolved, node)
+	}
+	return "", nil, &fs.PathError{Op: "open", Path: name, Err: errTooManyLinks}
+}
+
+// resolveParent follows symlinks in the directories leading to name, but not
+// in name itself. node is nil when name doesn't exist.
+func (m *MemoryFS) resolveParent(name string) (string, *memoryNode, error) {
+	if name == "." {
+		return name, m.nodes[name], nil
+	}
+
+	directory, base := path.Split(name)
+	directory = path.Clean(directory)
+	if directory != "." {
+		resolved, node, err := m.resolve(directory)
+		if err != nil {
+			return "", nil, err
+		}
+		if node == nil {
+			return "", nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+		if !node.mode.IsDir() {
+			return "", nil, &fs.PathError{Op: "stat", Path: name, Err: errNotDirectory}
+		}
+		directory = resolved
+	}
+
+	name = path.Join(directory, base)
+	return name, m.nodes[name], nil
+}
+
+func (m *MemoryFS) linkTarget(name string, node *memoryNode) string {
+	if strings.HasPrefix(node.target, "/") {
+		return memoryPath(node.target)
+	}
+	return memoryPath(path.Join(path.Dir(name), node.target))
+}
+
+// lookup finds name without following it if it is a symlink.
+func (m *MemoryFS) lookup(op, name string) (string, *memoryNode, error) {
+	resolved, node, err := m.resolveParent(memoryPath(name))
+	if err != nil {
+		return "", nil, err
+	}
+	if node == nil {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return resolved, node, nil
+}
+
+// create checks that name can be created: its parent is a directory and
+// nothing is there yet.
+func (m *MemoryFS) create(op, name string) (string, error) {
+	resolved, node, err := m.resolveParent(memoryPath(name))
+	if err != nil {
+		return "", err
+	}
+	if node != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	parent, ok := m.nodes[path.Dir(resolved)]
+	if !ok {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return "", &fs.PathError{Op: op, Path: name, Err: errNotDirectory}
+	}
+	return resolved, nil
+}
+
+// put stores node under name and indexes it in its directory.
+func (m *MemoryFS) put(name string, node *memoryNode) {
+	m.nodes[name] = node
+	if name == "." {
+		return
+	}
+	directory := path.Dir(name)
+	if m.children[directory] == nil {
+		m.children[directory] = make(map[string]bool)
+	}
+	m.children[directory][name] = true
+}
+
+// drop removes name and everything below it.
+func (m *MemoryFS) drop(name string) {
+	for child := range m.children[name] {
+		m.drop(child)
+	}
+	delete(m.children, name)
+	delete(m.nodes, name)
+	delete(m.children[path.Dir(name)], name)
+}
+
+// subtree returns name and everything below it, by name.
+func (m *MemoryFS) subtree(name string, nodes map[string]*memoryNode) map[string]*memoryNode {
+	nodes[name] = m.nodes[name]
+	for child := range m.children[name] {
+		m.subtree(child, nodes)
+	}
+	return nodes
+}
+
+// list returns the names directly inside directory, sorted.
+func (m *MemoryFS) list(directory string) []string {
+	names := make([]string, 0, len(m.children[directory]))
+	for name := range m.children[directory] {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (m *MemoryFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, node, err := m.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	file := &memoryFile{info: memoryInfo{name: path.Base(resolved), node: *node}}
+	if node.mode.IsDir() {
+		file.entries = m.entries(resolved)
+	} else {
+		zeros := io.LimitReader(zeroReader{}, node.size-int64(len(node.data)))
+		file.reader = io.MultiReader(bytes.NewReader(node.data), zeros)
+	}
+	return file, nil
+}
+
+func (m *MemoryFS) entries(directory string) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0)
+	for _, child := range m.list(directory) {
+		entries = append(entries, fs.FileInfoToDirEntry(memoryInfo{name: path.Base(child), node: *m.nodes[child]}))
+	}
+	return entries
+}
+
+func (m *MemoryFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, node, err := m.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDirectory}
+	}
+	return m.entries(resolved), nil
+}
+
+func (m *MemoryFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, node, err := m.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memoryInfo{name: path.Base(resolved), node: *node}, nil
+}
+
+func (m *MemoryFS) Lstat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, node, err := m.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memoryInfo{name: path.Base(resolved), node: *node}, nil
+}
+
+func (m *MemoryFS) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return node.target, nil
+}
+
+func (m *MemoryFS) Mkdir(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.create("mkdir", name)
+	if err != nil {
+		return err
+	}
+	m.put(resolved, &memoryNode{mode: fs.ModeDir | 0o755, modTime: m.now()})
+	return nil
+}
+
+func (m *MemoryFS) Symlink(target, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.create("symlink", name)
+	if err != nil {
+		return err
+	}
+	m.put(resolved, &memoryNode{mode: fs.ModeSymlink | 0o777, target: target, modTime: m.now()})
+	return nil
+}
+
+// Link creates a hard link. Files in a MemoryFS have no identity beyond their
+// content, so the link is a copy that shares it.
+func (m *MemoryFS) Link(target, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup("link", target)
+	if err != nil {
+		return err
+	}
+	if node.mode.IsDir() {
+		return &fs.PathError{Op: "link", Path: target, Err: fs.ErrPermission}
+	}
+	resolved, err := m.create("link", name)
+	if err != nil {
+		return err
+	}
+	linked := *node
+	m.put(resolved, &linked)
+	return nil
+}
+
+func (m *MemoryFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, node, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	if resolved == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	if node.mode.IsDir() && len(m.children[resolved]) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errDirectoryNotEmpty}
+	}
+	m.drop(resolved)
+	return nil
+}
+
+func (m *MemoryFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, _, err := m.lookup("remove", name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resolved == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	m.drop(resolved)
+	return nil
+}
+
+func (m *MemoryFS) Rename(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, _, err := m.lookup("rename", oldName)
+	if err != nil {
+		return err
+	}
+	to, err := m.create("rename", newName)
+	if err != nil {
+		return err
+	}
+	if to == from || strings.HasPrefix(to, from+"/") {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrInvalid}
+	}
+	m.move(from, to)
+	return nil
+}
+
+// Exchange swaps two paths at once, like renameat2 with RENAME_EXCHANGE.
+func (m *MemoryFS) Exchange(a, b string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	first, _, err := m.lookup("exchange", a)
+	if err != nil {
+		return err
+	}
+	second, _, err := m.lookup("exchange", b)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(first, second+"/") || strings.HasPrefix(second, first+"/") {
+		return &fs.PathError{Op: "exchange", Path: a, Err: fs.ErrInvalid}
+	}
+
+	const temporary = "\x00exchange"
+	m.move(first, temporary)
+	m.move(second, first)
+	m.move(temporary, second)
+	return nil
+}
+
+// move renames from and everything below it to to.
+func (m *MemoryFS) move(from, to string) {
+	moved := m.subtree(from, make(map[string]*memoryNode))
+	m.drop(from)
+	for key, node := range moved {
+		m.put(to+key[len(from):], node)
+	}
+}
+
+type memoryInfo struct {
+	name string
+	node memoryNode
+}
+
+func (i memoryInfo) Name() string       { return i.name }
+func (i memoryInfo) Size() int64        { return i.node.size }
+func (i memoryInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memoryInfo) ModTime() time.Time { return i.node.modTime }
+func (i memoryInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memoryInfo) Sys() any           { return nil }
+
+type memoryFile struct {
+	info    memoryInfo
+	reader  io.Reader
+	entries []fs.DirEntry
+}
+
+func (f *memoryFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memoryFile) Read(buffer []byte) (int, error) {
+	if f.reader == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.info.name, Err: errIsDirectory}
+	}
+	return f.reader.Read(buffer)
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
+
+// ReadDir makes directories fs.ReadDirFile.
+func (f *memoryFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.reader != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.info.name, Err: errNotDirectory}
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(buffer []byte) (int, error) {
+	clear(buffer)
+	return len(buffer), nil
+}
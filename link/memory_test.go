@@ -0,0 +1,110 @@
+package link
+
+import (
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+)
+
+func TestMemoryFSReadDir(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(m *MemoryFS) error
+		want   map[string][]string
+	}{
+		{
+			name:   "lists entries sorted",
+			change: func(m *MemoryFS) error { return nil },
+			want: map[string][]string{
+				".":       {"a", "b"},
+				"a":       {"1.mkv", "2.mkv", "sub"},
+				"/a/sub":  {"3.mkv"},
+				"b":       {"link"},
+				"/b/link": {"1.mkv", "2.mkv", "sub"},
+			},
+		},
+		{
+			name:   "renames directories",
+			change: func(m *MemoryFS) error { return m.Rename("/a", "/c") },
+			want: map[string][]string{
+				".":     {"b", "c"},
+				"c":     {"1.mkv", "2.mkv", "sub"},
+				"c/sub": {"3.mkv"},
+			},
+		},
+		{
+			name: "exchanges directories",
+			change: func(m *MemoryFS) error {
+				if err := m.MkdirAll("/c/new"); err != nil {
+					return err
+				}
+				return m.Exchange("/a", "/c")
+			},
+			want: map[string][]string{
+				".":     {"a", "b", "c"},
+				"a":     {"new"},
+				"c":     {"1.mkv", "2.mkv", "sub"},
+				"c/sub": {"3.mkv"},
+			},
+		},
+		{
+			name:   "removes trees",
+			change: func(m *MemoryFS) error { return m.RemoveAll("/a") },
+			want:   map[string][]string{".": {"b"}},
+		},
+		{
+			name:   "removes entries",
+			change: func(m *MemoryFS) error { return m.Remove("/a/sub/3.mkv") },
+			want: map[string][]string{
+				"a":     {"1.mkv", "2.mkv", "sub"},
+				"a/sub": {},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestFS(t, "/a/2.mkv", "/a/1.mkv", "/a/sub/3.mkv", "/b/link -> /a")
+			if err := test.change(m); err != nil {
+				t.Fatal(err)
+			}
+
+			for directory, want := range test.want {
+				entries, err := m.ReadDir(directory)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := make([]string, 0, len(entries))
+				for _, entry := range entries {
+					got = append(got, entry.Name())
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("%s: got %q, want %q", directory, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryFSRemoveNonEmpty(t *testing.T) {
+	m := newTestFS(t, "/a/1.mkv")
+	if err := m.Remove("/a"); err == nil {
+		t.Error("removed a directory that isn't empty")
+	}
+}
+
+func BenchmarkMemoryFSWalk(b *testing.B) {
+	m := NewMemoryFS()
+	for show := range 520 {
+		for episode := range 100 {
+			if err := m.WriteFile(fmt.Sprintf("/downloads/Show %d/E%d.mkv", show, episode), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	for b.Loop() {
+		fs.WalkDir(m, "/downloads", func(string, fs.DirEntry, error) error { return nil })
+	}
+}
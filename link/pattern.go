@@ -0,0 +1,188 @@
+package link
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Regex engines source patterns can be compiled with.
+const (
+	RE2Engine  = "re2"
+	PCREEngine = "pcre"
+)
+
+// Engines lists every regex engine, the default one first.
+var Engines = []string{RE2Engine, PCREEngine}
+
+// DefaultMatchTimeout is how long the pcre engine may spend matching a single
+// path by default.
+const DefaultMatchTimeout = time.Second
+
+// Pattern matches source paths and captures the values the destination
+// template is filled with.
+type Pattern interface {
+	// String returns the source of the pattern.
+	String() string
+	// Match returns the whole match followed by every capture group, or nil
+	// when s doesn't match.
+	Match(s string) ([]string, error)
+	// SubexpNames returns the name of every capture group, like
+	// regexp.Regexp.SubexpNames: the whole match comes first, and unnamed
+	// groups have an empty name.
+	SubexpNames() []string
+}
+
+// CompilePattern compiles pattern with the given engine. re2 is Go's regexp
+// package, which runs in linear time but has no lookarounds or
+// backreferences. pcre is a backtracking engine supporting both, as well as
+// atomic groups; it gives up on a path after timeout, so a catastrophic
+// pattern can't hang a run. Both number groups in the order they open, named
+// or not, so $N means the same group with either engine.
+func CompilePattern(pattern, engine string, timeout time.Duration) (Pattern, error) {
+	switch engine {
+	case RE2Engine, "":
+		exp, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re2Pattern{exp}, nil
+	case PCREEngine:
+		exp, err := regexp2.Compile(pattern, regexp2.RE2)
+		if err != nil {
+			return nil, err
+		}
+		exp.MatchTimeout = timeout
+		return pcrePattern{exp, groupNumbers(exp, pattern)}, nil
+	default:
+		return nil, fmt.Errorf("unknown regex engine: %s", engine)
+	}
+}
+
+type re2Pattern struct {
+	*regexp.Regexp
+}
+
+func (p re2Pattern) Match(s string) ([]string, error) {
+	return p.FindStringSubmatch(s), nil
+}
+
+type pcrePattern struct {
+	*regexp2.Regexp
+	// numbers are the group numbers of the engine, in the order the groups
+	// open in the pattern.
+	numbers []int
+}
+
+func (p pcrePattern) Match(s string) ([]string, error) {
+	match, err := p.FindStringMatch(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match %s: %w", s, err)
+	}
+	if match == nil {
+		return nil, nil
+	}
+
+	matches := make([]string, 0)
+	for _, number := range p.numbers {
+		matches = append(matches, match.GroupByNumber(number).String())
+	}
+	return matches, nil
+}
+
+func (p pcrePattern) SubexpNames() []string {
+	names := make([]string, 0)
+	for _, number := range p.numbers {
+		name := p.GroupNameFromNumber(number)
+		if name == strconv.Itoa(number) {
+			name = ""
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// groupNumbers orders the group numbers of exp the way re2 would number the
+// groups: by where they open in pattern. regexp2 numbers named groups after
+// the unnamed ones, like .NET. Its own order is kept when the pattern can't be
+// read.
+func groupNumbers(exp *regexp2.Regexp, pattern string) []int {
+	numbers := []int{0}
+	seen := map[int]bool{0: true}
+	unnamed := 0
+	for _, name := range groupNames(pattern) {
+		number := 0
+		if name == "" {
+			unnamed++
+			number = unnamed
+		} else {
+			number = exp.GroupNumberFromName(name)
+		}
+		if number < 0 {
+			return exp.GetGroupNumbers()
+		}
+		if !seen[number] {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+	if len(numbers) != len(exp.GetGroupNumbers()) {
+		return exp.GetGroupNumbers()
+	}
+	return numbers
+}
+
+// groupNames returns the name of every capture group in pattern, in the order
+// they open, with an empty name for unnamed groups. Escapes, character classes
+// and groups that don't capture are skipped.
+func groupNames(pattern string) []string {
+	names := make([]string, 0)
+	class := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+			// A ] right after the opening bracket, or after a negation, is
+			// part of the class.
+			if strings.HasPrefix(pattern[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(pattern[i+1:], "]") {
+				i++
+			}
+		case c == '(' && !strings.HasPrefix(pattern[i+1:], "?"):
+			names = append(names, "")
+		case c == '(':
+			if name, ok := groupName(pattern[i+2:]); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// groupName returns the name of the group whose opening is followed by rest,
+// rest starting after "(?". It is false for groups that don't capture, like
+// lookarounds.
+func groupName(rest string) (string, bool) {
+	if strings.HasPrefix(rest, "<=") || strings.HasPrefix(rest, "<!") {
+		return "", false
+	}
+	for opening, closing := range map[string]string{"P<": ">", "<": ">", "'": "'"} {
+		if !strings.HasPrefix(rest, opening) {
+			continue
+		}
+		if end := strings.Index(rest[len(opening):], closing); end >= 0 {
+			return rest[len(opening) : len(opening)+end], true
+		}
+	}
+	return "", false
+}
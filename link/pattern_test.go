@@ -0,0 +1,84 @@
+package link
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		engines   []string
+		input     string
+		wantMatch []string
+		wantNames []string
+	}{
+		{
+			name:      "unnamed groups",
+			pattern:   `(\w+) - ([0-9]+)\.mkv`,
+			engines:   Engines,
+			input:     "Show - 01.mkv",
+			wantMatch: []string{"Show - 01.mkv", "Show", "01"},
+			wantNames: []string{"", "", ""},
+		},
+		{
+			name:      "named groups before unnamed ones",
+			pattern:   `(?P<show>\w+) - ([0-9]+)\.mkv`,
+			engines:   Engines,
+			input:     "Show - 01.mkv",
+			wantMatch: []string{"Show - 01.mkv", "Show", "01"},
+			wantNames: []string{"", "show", ""},
+		},
+		{
+			name:      "groups that don't capture",
+			pattern:   `(?:\[\w+\] )?(?P<show>\w+) - ([0-9]+)[(]?\.mkv`,
+			engines:   Engines,
+			input:     "[Grp] Show - 01.mkv",
+			wantMatch: []string{"[Grp] Show - 01.mkv", "Show", "01"},
+			wantNames: []string{"", "show", ""},
+		},
+		{
+			name:      "lookarounds and other group names",
+			pattern:   `(?!NC)(?<show>\w+)(?<= - |\w)(?'sep' - )([0-9]+)\.mkv`,
+			engines:   []string{PCREEngine},
+			input:     "Show - 01.mkv",
+			wantMatch: []string{"Show - 01.mkv", "Show", " - ", "01"},
+			wantNames: []string{"", "show", "sep", ""},
+		},
+		{
+			name:      "no match",
+			pattern:   `([0-9]+)\.mkv`,
+			engines:   Engines,
+			input:     "Show.mkv",
+			wantNames: []string{"", ""},
+		},
+	}
+
+	for _, test := range tests {
+		for _, engine := range test.engines {
+			t.Run(test.name+"/"+engine, func(t *testing.T) {
+				pattern, err := CompilePattern(test.pattern, engine, DefaultMatchTimeout)
+				if err != nil {
+					t.Fatal(err)
+				}
+				match, err := pattern.Match(test.input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(match, test.wantMatch) {
+					t.Errorf("got match %q, want %q", match, test.wantMatch)
+				}
+				if names := pattern.SubexpNames(); !slices.Equal(names, test.wantNames) {
+					t.Errorf("got names %q, want %q", names, test.wantNames)
+				}
+			})
+		}
+	}
+}
+
+func TestCompilePatternUnknownEngine(t *testing.T) {
+	if _, err := CompilePattern(`.*`, "perl", 0); err == nil {
+		t.Error("got no error for an unknown engine")
+	}
+}
@@ -0,0 +1,251 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const regexConstants = ".*+?[]()|{}"
+
+// Planner plans a symlink for every source path matching Pattern.
+type Planner struct {
+	Pattern  Pattern
+	Template Template
+	// FS is where source paths are walked. OS when nil.
+	FS fs.FS
+	// Linker is where destinations are checked. OS when nil.
+	Linker Linker
+	// Steps numbers the items for $STEP and $STEP_COUNT. Nil when the
+	// template doesn't use steps.
+	Steps *StepCounter
+	// Replace plans symlinks pointing somewhere else to be replaced rather
+	// than reported as conflicts.
+	Replace bool
+	// Linked holds sources to leave out, usually because a previous run
+	// linked them already.
+	Linked map[string]bool
+	// Roots are the directories destinations have to stay within once
+	// cleaned. The template root when empty.
+	Roots []string
+	// AllowSeparators lets captured values contain path separators or be
+	// "." or "..", so that they can name subdirectories. Such values are
+	// refused otherwise.
+	AllowSeparators bool
+}
+
+// ErrUnsafeDestination is returned for destinations that would escape the
+// allowed roots, or that captured values would move to another directory.
+var ErrUnsafeDestination = errors.New("unsafe destination")
+
+// NewPlanner compiles the source pattern, which has to match whole paths, and
+// parses the destination template.
+func NewPlanner(pattern, template string) (*Planner, error) {
+	return NewPlannerWithEngine(pattern, template, RE2Engine, 0)
+}
+
+// NewPlannerWithEngine is NewPlanner with the source pattern compiled by
+// another regex engine. See CompilePattern.
+func NewPlannerWithEngine(pattern, template, engine string, timeout time.Duration) (*Planner, error) {
+	if !strings.HasSuffix(pattern, "$") {
+		pattern += "$"
+	}
+
+	exp, err := CompilePattern(pattern, engine, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source pattern: %w", err)
+	}
+
+	return &Planner{Pattern: exp, Template: NewTemplate(template)}, nil
+}
+
+// RootDirectory returns the deepest directory of the source pattern that
+// doesn't contain any regex syntax.
+func (p *Planner) RootDirectory() string {
+	return RootDirectory(p.Pattern.String())
+}
+
+// RootDirectory returns the deepest directory of pattern that doesn't contain
+// any regex syntax.
+func RootDirectory(pattern string) string {
+	for i, c := range pattern {
+		if strings.ContainsRune(regexConstants, c) {
+			return filepath.Dir(pattern[:i])
+		}
+	}
+	return filepath.Dir(pattern)
+}
+
+// Walk lists every path under the root directory of the pattern, in lexical
+// order.
+func (p *Planner) Walk(ctx context.Context) ([]string, error) {
+	paths := make([]string, 0)
+
+	rootDirectory := p.RootDirectory()
+	slog.Debug("Searching in root directory", "root", rootDirectory)
+
+	err := fs.WalkDir(p.fs(), rootDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", rootDirectory, err)
+	}
+
+	return paths, nil
+}
+
+// Plan returns an item for every path matching the pattern, in the order the
+// paths were given. Sources in Linked are left out.
+func (p *Planner) Plan(ctx context.Context, paths []string) (Plan, error) {
+	plan := make(Plan, 0)
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		matches, err := p.Pattern.Match(path)
+		if err != nil {
+			slog.Warn("Skipping path that could not be matched", "path", path, "error", err)
+			continue
+		}
+		if matches == nil {
+			continue
+		}
+		if p.Linked[path] {
+			slog.Debug("Path already linked by a previous run", "path", path)
+			continue
+		}
+		slog.Debug("Path matched", "path", path)
+
+		// Captures are checked before numbering, so that refused paths
+		// don't use up a step.
+		captures := matches[1:]
+		if err := p.checkCaptures(captures); err != nil {
+			destination, fillErr := p.Template.Fill(captures, 0, 0)
+			if fillErr != nil {
+				return nil, fillErr
+			}
+			plan = append(plan, PlanItem{
+				Source:      path,
+				Destination: destination,
+				Captures:    captures,
+				Action:      RefuseAction,
+				Error:       err.Error(),
+			})
+			continue
+		}
+
+		step, stepCount := 0, 0
+		if p.Steps != nil {
+			var err error
+			step, stepCount, err = p.Steps.Next()
+			if err != nil {
+				return nil, fmt.Errorf("failed to number %s: %w", path, err)
+			}
+		}
+
+		destination, err := p.Template.Fill(captures, step, stepCount)
+		if err != nil {
+			return nil, err
+		}
+
+		item := PlanItem{
+			Source:      path,
+			Destination: destination,
+			Captures:    captures,
+			Step:        step,
+			StepCount:   stepCount,
+			Action:      Action(p.linker(), path, destination, p.Replace),
+		}
+		if err := CheckRoots(p.roots(), destination); err != nil {
+			item.Action = RefuseAction
+			item.Error = err.Error()
+		}
+		plan = append(plan, item)
+	}
+
+	return plan, nil
+}
+
+// checkCaptures refuses captured values that would change directories. Only
+// the groups the template uses are checked.
+func (p *Planner) checkCaptures(captures []string) error {
+	if p.AllowSeparators {
+		return nil
+	}
+	for _, token := range p.Template.Tokens() {
+		if token.Kind != CaptureToken || token.Index < 1 || token.Index > len(captures) {
+			continue
+		}
+		capture := captures[token.Index-1]
+		if strings.ContainsAny(capture, "/"+string(filepath.Separator)) || capture == "." || capture == ".." {
+			return fmt.Errorf("%w: $%d is %q, which would change directories", ErrUnsafeDestination, token.Index, capture)
+		}
+	}
+	return nil
+}
+
+// roots returns the directories destinations have to stay within.
+func (p *Planner) roots() []string {
+	if len(p.Roots) == 0 {
+		return []string{p.Template.Root()}
+	}
+	return p.Roots
+}
+
+// CheckRoots returns an error wrapping ErrUnsafeDestination unless
+// destination, once cleaned, is inside one of roots.
+func CheckRoots(roots []string, destination string) error {
+	for _, root := range roots {
+		if IsWithin(root, destination) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is outside of %s", ErrUnsafeDestination, filepath.Clean(destination), strings.Join(roots, ", "))
+}
+
+// IsWithin reports whether path, once cleaned, is strictly inside root.
+// Relative paths are resolved from the working directory, so relative roots
+// hold absolute paths too.
+func IsWithin(root, path string) bool {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return relative != "." && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+func (p *Planner) fs() fs.FS {
+	if p.FS == nil {
+		return OS
+	}
+	return p.FS
+}
+
+func (p *Planner) linker() Linker {
+	if p.Linker == nil {
+		return OS
+	}
+	return p.Linker
+}
@@ -0,0 +1,249 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestFS returns a MemoryFS holding the given files. Names ending with
+// " -> target" are symlinks to target instead.
+func newTestFS(t *testing.T, files ...string) *MemoryFS {
+	t.Helper()
+
+	m := NewMemoryFS()
+	for _, file := range files {
+		name, target, isLink := strings.Cut(file, " -> ")
+		var err error
+		if isLink {
+			if err = m.MkdirAll(parentOf(name)); err == nil {
+				err = m.Symlink(target, name)
+			}
+		} else {
+			err = m.WriteFile(name, nil)
+		}
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", file, err)
+		}
+	}
+	return m
+}
+
+func parentOf(name string) string {
+	return name[:strings.LastIndex(name, "/")]
+}
+
+// planOn walks and plans pattern and template on m.
+func planOn(t *testing.T, m *MemoryFS, pattern, template string, configure func(*Planner)) Plan {
+	t.Helper()
+
+	planner, err := NewPlanner(pattern, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planner.FS = m
+	planner.Linker = m
+	if configure != nil {
+		configure(planner)
+	}
+
+	paths, err := planner.Walk(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := planner.Plan(context.Background(), paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+type plannedItem struct {
+	destination string
+	action      string
+}
+
+func TestPlannerPlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []string
+		pattern   string
+		template  string
+		configure func(*Planner)
+		want      []plannedItem
+	}{
+		{
+			name:     "creates padded destinations",
+			files:    []string{"/downloads/Show - 1.mkv", "/downloads/Show - 2.mkv", "/downloads/notes.txt"},
+			pattern:  `/downloads/Show - ([0-9]+)\.mkv`,
+			template: "/library/Show/Show E${1:2}.mkv",
+			want: []plannedItem{
+				{"/library/Show/Show E01.mkv", CreateAction},
+				{"/library/Show/Show E02.mkv", CreateAction},
+			},
+		},
+		{
+			name:     "numbers steps",
+			files:    []string{"/downloads/a 1.mkv", "/downloads/a 2.mkv", "/downloads/a 3.mkv"},
+			pattern:  `/downloads/a ([0-9]+)\.mkv`,
+			template: "/library/Season $STEP/E${STEP_COUNT:2}.mkv",
+			configure: func(p *Planner) {
+				p.Steps = NewStepCounter([]int{2, 1})
+			},
+			want: []plannedItem{
+				{"/library/Season 1/E01.mkv", CreateAction},
+				{"/library/Season 1/E02.mkv", CreateAction},
+				{"/library/Season 2/E01.mkv", CreateAction},
+			},
+		},
+		{
+			name:     "skips existing links and reports conflicts",
+			files:    []string{"/downloads/1.mkv", "/downloads/2.mkv", "/library/1.mkv -> /downloads/1.mkv", "/library/2.mkv"},
+			pattern:  `/downloads/([0-9]+)\.mkv`,
+			template: "/library/$1.mkv",
+			want: []plannedItem{
+				{"/library/1.mkv", SkipAction},
+				{"/library/2.mkv", ConflictAction},
+			},
+		},
+		{
+			name:     "reports symlinks pointing elsewhere as conflicts",
+			files:    []string{"/downloads/1.mkv", "/library/1.mkv -> /elsewhere/1.mkv"},
+			pattern:  `/downloads/([0-9]+)\.mkv`,
+			template: "/library/$1.mkv",
+			want:     []plannedItem{{"/library/1.mkv", ConflictAction}},
+		},
+		{
+			name:      "replaces symlinks pointing elsewhere",
+			files:     []string{"/downloads/1.mkv", "/library/1.mkv -> /elsewhere/1.mkv"},
+			pattern:   `/downloads/([0-9]+)\.mkv`,
+			template:  "/library/$1.mkv",
+			configure: func(p *Planner) { p.Replace = true },
+			want:      []plannedItem{{"/library/1.mkv", ReplaceAction}},
+		},
+		{
+			name:     "refuses captures changing directories",
+			files:    []string{"/downloads/Show/1.mkv", "/downloads/...mkv"},
+			pattern:  `/downloads/(.+)\.mkv`,
+			template: "/library/$1.mkv",
+			want: []plannedItem{
+				{"/library/...mkv", RefuseAction},
+				{"/library/Show/1.mkv", RefuseAction},
+			},
+		},
+		{
+			name:      "allows separators when asked",
+			files:     []string{"/downloads/Show/1.mkv"},
+			pattern:   `/downloads/(.+)\.mkv`,
+			template:  "/library/$1.mkv",
+			configure: func(p *Planner) { p.AllowSeparators = true },
+			want:      []plannedItem{{"/library/Show/1.mkv", CreateAction}},
+		},
+		{
+			name:     "ignores groups the template doesn't use",
+			files:    []string{"/downloads/Show/Extras/1.mkv"},
+			pattern:  `/downloads/(.+)/([0-9]+)\.mkv`,
+			template: "/library/E$2.mkv",
+			want:     []plannedItem{{"/library/E1.mkv", CreateAction}},
+		},
+		{
+			name:      "refuses destinations escaping the root",
+			files:     []string{"/downloads/1.mkv"},
+			pattern:   `/downloads/([0-9]+)\.mkv`,
+			template:  "/library/Show/$1/../../../etc/$1.mkv",
+			configure: func(p *Planner) { p.AllowSeparators = true },
+			want:      []plannedItem{{"/library/Show/1/../../../etc/1.mkv", RefuseAction}},
+		},
+		{
+			name:      "keeps destinations inside other roots",
+			files:     []string{"/downloads/1.mkv", "/downloads/2.mkv"},
+			pattern:   `/downloads/([0-9]+)\.mkv`,
+			template:  "/library/Show/../Movies/$1.mkv",
+			configure: func(p *Planner) { p.Roots = []string{"/library/Movies"} },
+			want: []plannedItem{
+				{"/library/Show/../Movies/1.mkv", CreateAction},
+				{"/library/Show/../Movies/2.mkv", CreateAction},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := planOn(t, newTestFS(t, test.files...), test.pattern, test.template, test.configure)
+
+			if len(plan) != len(test.want) {
+				t.Fatalf("got %d items, want %d: %+v", len(plan), len(test.want), plan)
+			}
+			for i, want := range test.want {
+				got := plannedItem{plan[i].Destination, plan[i].Action}
+				if got != want {
+					t.Errorf("item %d: got %+v, want %+v", i, got, want)
+				}
+				if (plan[i].Action == RefuseAction) != (plan[i].Error != "") {
+					t.Errorf("item %d: action %s with error %q", i, plan[i].Action, plan[i].Error)
+				}
+			}
+		})
+	}
+}
+
+func TestPlannerPlanLeavesLinkedSourcesOut(t *testing.T) {
+	m := newTestFS(t, "/downloads/1.mkv", "/downloads/2.mkv")
+	plan := planOn(t, m, `/downloads/([0-9]+)\.mkv`, "/library/$1.mkv", func(p *Planner) {
+		p.Linked = map[string]bool{"/downloads/1.mkv": true}
+	})
+
+	if len(plan) != 1 || plan[0].Source != "/downloads/2.mkv" {
+		t.Errorf("got %+v, want only /downloads/2.mkv", plan)
+	}
+}
+
+func TestCheckRoots(t *testing.T) {
+	tests := []struct {
+		roots       []string
+		destination string
+		ok          bool
+	}{
+		{[]string{"/library"}, "/library/Show/1.mkv", true},
+		{[]string{"/library"}, "/library/Show/../1.mkv", true},
+		{[]string{"/library"}, "/library/../1.mkv", false},
+		{[]string{"/library"}, "/library", false},
+		{[]string{"/library"}, "/library2/1.mkv", false},
+		{[]string{"/library/"}, "/library/1.mkv", true},
+		{[]string{"/movies", "/library"}, "/library/1.mkv", true},
+		{[]string{"/movies", "/library"}, "/shows/1.mkv", false},
+	}
+
+	for _, test := range tests {
+		err := CheckRoots(test.roots, test.destination)
+		if test.ok && err != nil {
+			t.Errorf("CheckRoots(%q, %q) = %v, want nil", test.roots, test.destination, err)
+		}
+		if !test.ok && !errors.Is(err, ErrUnsafeDestination) {
+			t.Errorf("CheckRoots(%q, %q) = %v, want ErrUnsafeDestination", test.roots, test.destination, err)
+		}
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		root string
+		path string
+		want bool
+	}{
+		{"/lib/Show", "/lib/Show/a.mkv", true},
+		{"/lib/Show", "/lib/Show2/b.mkv", false},
+		{"/lib/Show", "/lib/Show", false},
+		{"/lib/Show", "/lib/Show/../Show2/b.mkv", false},
+		{"/lib/Show", "/lib/Show/..b.mkv", true},
+		{"/", "/lib", true},
+		{"lib", "lib/Show/a.mkv", true},
+	}
+
+	for _, test := range tests {
+		if got := IsWithin(test.root, test.path); got != test.want {
+			t.Errorf("IsWithin(%q, %q) = %v, want %v", test.root, test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	StagingSuffix = ".supalink-staging"
+	BackupSuffix  = ".supalink-backup"
+)
+
+// Stage builds every symlink of the plan in a staging directory next to root,
+// checks the result and swaps it into place, so root goes from its previous
+// state to the new one at once. The previous tree is kept next to it as a
+// backup. Nothing in root is touched unless the whole staging tree could be
+// built, and the results of the items say what happened either way. The
+// Linker of the Applier has to be a Swapper.
+func (a *Applier) Stage(ctx context.Context, plan Plan, root string) error {
+	swapper, err := a.swapper()
+	if err != nil {
+		return err
+	}
+	root, err = StagingRoot(root)
+	if err != nil {
+		return err
+	}
+	staging := root + StagingSuffix
+
+	staged := slices.Clone(plan)
+	for i := range staged {
+		// Refused items are never created, wherever they would go.
+		if staged[i].Action == RefuseAction {
+			continue
+		}
+		relative, err := filepath.Rel(root, staged[i].Destination)
+		if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(os.PathSeparator)) {
+			return fmt.Errorf("cannot stage %s: it is outside of %s", staged[i].Destination, root)
+		}
+		staged[i].Destination = filepath.Join(staging, relative)
+	}
+
+	if err := swapper.RemoveAll(staging); err != nil {
+		return fmt.Errorf("failed to clear staging directory: %w", err)
+	}
+	if _, err := mkdirAll(swapper, staging); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	slog.Info("Building destination tree in staging directory", "staging", staging)
+
+	staged.Replan(swapper, false)
+	err = a.Apply(ctx, staged)
+	if err == nil {
+		err = validateStaging(swapper, staged)
+	}
+	if err == nil {
+		err = swapIntoPlace(swapper, staging, root)
+	}
+
+	for i := range plan {
+		plan[i].Result = staged[i].Result
+		plan[i].Error = staged[i].Error
+	}
+
+	if err != nil {
+		swapper.RemoveAll(staging)
+		for i := range plan {
+			if plan[i].Result == CreatedResult || plan[i].Result == ReplacedResult {
+				plan[i].Result = RolledBackResult
+			}
+		}
+		return fmt.Errorf("%w, %s was left untouched", err, root)
+	}
+
+	return nil
+}
+
+// Restore swaps the tree replaced by the last Stage into root again. The tree
+// it replaces becomes the backup, so restoring twice undoes the restore.
+func (a *Applier) Restore(root string) error {
+	swapper, err := a.swapper()
+	if err != nil {
+		return err
+	}
+	root, err = StagingRoot(root)
+	if err != nil {
+		return err
+	}
+	backup := root + BackupSuffix
+
+	if _, err := swapper.Lstat(backup); err != nil {
+		return fmt.Errorf("no backup to restore: %w", err)
+	}
+
+	return swapIntoPlace(swapper, backup, root)
+}
+
+func (a *Applier) swapper() (Swapper, error) {
+	swapper, ok := a.linker().(Swapper)
+	if !ok {
+		return nil, fmt.Errorf("staging needs a linker that can rename and exchange directories")
+	}
+	return swapper, nil
+}
+
+// StagingRoot checks that root can be swapped, which rules out the working
+// directory and the filesystem root, and returns it cleaned.
+func StagingRoot(root string) (string, error) {
+	root = filepath.Clean(root)
+	if root == "." || root == filepath.Dir(root) {
+		return "", fmt.Errorf("cannot stage into %s, the destination template needs a parent directory", root)
+	}
+	return root, nil
+}
+
+// validateStaging makes sure every item that should be in the staging tree is
+// there and points at its source.
+func validateStaging(linker Linker, staged Plan) error {
+	failed := 0
+	for _, item := range staged {
+		switch item.Result {
+		case FailedResult:
+			failed++
+		case CreatedResult, ReplacedResult:
+			if !IsLinkedTo(linker, item.Destination, item.Source) {
+				return fmt.Errorf("staged symlink %s does not point at %s", item.Destination, item.Source)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to stage %d of %d symlinks", failed, len(staged))
+	}
+	return nil
+}
+
+// swapIntoPlace moves directory to root. When root already exists the two are
+// exchanged atomically, and what used to be root becomes the backup,
+// replacing the previous one.
+func swapIntoPlace(swapper Swapper, directory, root string) error {
+	backup := root + BackupSuffix
+
+	if _, err := swapper.Lstat(root); errors.Is(err, fs.ErrNotExist) {
+		if _, err := mkdirAll(swapper, filepath.Dir(root)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(root), err)
+		}
+		if err := swapper.Rename(directory, root); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %w", directory, root, err)
+		}
+		slog.Info("Moved staged tree into place", "destination", root)
+		return nil
+	}
+
+	if directory != backup {
+		if err := swapper.RemoveAll(backup); err != nil {
+			return fmt.Errorf("failed to remove previous backup: %w", err)
+		}
+	}
+
+	if err := swapper.Exchange(directory, root); err != nil {
+		return fmt.Errorf("failed to swap %s with %s: %w", directory, root, err)
+	}
+
+	if directory != backup {
+		// The swap is done by now, so the previous tree is left where it
+		// is rather than failing the run.
+		if err := swapper.Rename(directory, backup); err != nil {
+			slog.Warn("Failed to move the previous tree to the backup", "previous", directory, "backup", backup, "error", err)
+			return nil
+		}
+	}
+	slog.Info("Swapped tree into place", "destination", root, "backup", backup)
+
+	return nil
+}
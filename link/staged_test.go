@@ -0,0 +1,116 @@
+package link
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApplierStage(t *testing.T) {
+	m := newTestFS(t, "/downloads/1.mkv", "/downloads/2.mkv", "/library/Show/old.mkv -> /downloads/old.mkv")
+	applier := &Applier{Linker: m}
+	plan := Plan{
+		{Source: "/downloads/1.mkv", Destination: "/library/Show/Season 1/1.mkv"},
+		{Source: "/downloads/2.mkv", Destination: "/library/Show/Season 1/2.mkv"},
+	}
+	plan.Replan(m, false)
+
+	if err := applier.Stage(context.Background(), plan, "/library/Show"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range plan {
+		if item.Result != CreatedResult {
+			t.Errorf("%s: got %s, want %s", item.Destination, item.Result, CreatedResult)
+		}
+		if !IsLinkedTo(m, item.Destination, item.Source) {
+			t.Errorf("%s doesn't link to %s", item.Destination, item.Source)
+		}
+	}
+	if _, err := m.Lstat("/library/Show/old.mkv"); err == nil {
+		t.Error("the previous tree is still in place")
+	}
+	if !IsLinkedTo(m, "/library/Show"+BackupSuffix+"/old.mkv", "/downloads/old.mkv") {
+		t.Error("the previous tree wasn't kept as the backup")
+	}
+	if _, err := m.Lstat("/library/Show" + StagingSuffix); err == nil {
+		t.Error("the staging directory was left behind")
+	}
+
+	if err := applier.Restore("/library/Show"); err != nil {
+		t.Fatal(err)
+	}
+	if !IsLinkedTo(m, "/library/Show/old.mkv", "/downloads/old.mkv") {
+		t.Error("the previous tree wasn't restored")
+	}
+	if !IsLinkedTo(m, "/library/Show"+BackupSuffix+"/Season 1/1.mkv", "/downloads/1.mkv") {
+		t.Error("the staged tree didn't become the backup")
+	}
+}
+
+func TestApplierStageIntoNewRoot(t *testing.T) {
+	m := newTestFS(t, "/downloads/1.mkv")
+	plan := Plan{{Source: "/downloads/1.mkv", Destination: "/library/Show/1.mkv"}}
+	plan.Replan(m, false)
+
+	if err := (&Applier{Linker: m}).Stage(context.Background(), plan, "/library/Show"); err != nil {
+		t.Fatal(err)
+	}
+	if !IsLinkedTo(m, "/library/Show/1.mkv", "/downloads/1.mkv") {
+		t.Error("the staged tree wasn't moved into place")
+	}
+	if _, err := m.Lstat("/library/Show" + BackupSuffix); err == nil {
+		t.Error("got a backup without a previous tree")
+	}
+}
+
+func TestApplierStageFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		plan Plan
+		root string
+	}{
+		{
+			name: "failing item",
+			plan: Plan{
+				{Source: "/downloads/1.mkv", Destination: "/library/Show/1.mkv"},
+				// 1.mkv is a symlink to a file, not a directory.
+				{Source: "/downloads/2.mkv", Destination: "/library/Show/1.mkv/2.mkv"},
+			},
+			root: "/library/Show",
+		},
+		{
+			name: "destination outside of the root",
+			plan: Plan{{Source: "/downloads/1.mkv", Destination: "/library/Movies/1.mkv"}},
+			root: "/library/Show",
+		},
+		{
+			name: "working directory",
+			plan: Plan{{Source: "/downloads/1.mkv", Destination: "1.mkv"}},
+			root: ".",
+		},
+		{
+			name: "filesystem root",
+			plan: Plan{{Source: "/downloads/1.mkv", Destination: "/1.mkv"}},
+			root: "/",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestFS(t, "/downloads/1.mkv", "/downloads/2.mkv", "/library/Show/old.mkv -> /downloads/old.mkv")
+			test.plan.Replan(m, false)
+
+			if err := (&Applier{Linker: m}).Stage(context.Background(), test.plan, test.root); err == nil {
+				t.Fatal("got no error")
+			}
+			if !IsLinkedTo(m, "/library/Show/old.mkv", "/downloads/old.mkv") {
+				t.Error("the previous tree was touched")
+			}
+			for _, item := range test.plan {
+				if item.Result == CreatedResult {
+					t.Errorf("%s is reported as created", item.Destination)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package link
+
+import "fmt"
+
+// StepCounter hands out $STEP and $STEP_COUNT values. Each step holds as
+// many items as its size, and the counter moves to the next step once the
+// current one is full.
+type StepCounter struct {
+	sizes     []int
+	step      int
+	stepCount int
+}
+
+func NewStepCounter(sizes []int) *StepCounter {
+	return &StepCounter{sizes: sizes}
+}
+
+// Resume positions the counter on the given step, so the next item continues
+// numbering where a previous run stopped.
+func (c *StepCounter) Resume(step, stepCount int) {
+	c.step = step
+	c.stepCount = stepCount
+}
+
+// Next returns the step and step count of the next item.
+func (c *StepCounter) Next() (int, int, error) {
+	if len(c.sizes) == 0 {
+		return 0, 0, fmt.Errorf("no steps defined")
+	}
+
+	if c.step == 0 {
+		c.step = 1
+		c.stepCount = 1
+		return c.step, c.stepCount, nil
+	}
+
+	if c.stepCount >= c.sizes[c.step-1] {
+		if c.step >= len(c.sizes) {
+			return 0, 0, fmt.Errorf("exceeded the number of defined steps")
+		}
+		c.step++
+		c.stepCount = 1
+	} else {
+		c.stepCount++
+	}
+
+	return c.step, c.stepCount, nil
+}
@@ -0,0 +1,11 @@
+//go:build linux
+
+package link
+
+import "golang.org/x/sys/unix"
+
+// exchange atomically swaps two paths, so there is never a moment where
+// neither of them exists.
+func exchange(a, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
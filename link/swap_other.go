@@ -0,0 +1,19 @@
+//go:build !linux
+
+package link
+
+import "os"
+
+// exchange swaps two paths. Without renameat2 this takes three renames, so
+// b is briefly missing.
+func exchange(a, b string) error {
+	temporary := b + ".supalink-swap"
+	if err := os.Rename(b, temporary); err != nil {
+		return err
+	}
+	if err := os.Rename(a, b); err != nil {
+		os.Rename(temporary, b)
+		return err
+	}
+	return os.Rename(temporary, a)
+}
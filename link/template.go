@@ -0,0 +1,181 @@
+package link
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	bracedParameterExp    = regexp.MustCompile(`\$\{([0-9]+|STEP_COUNT|STEP)(:[0-9]+)?\}`)
+	captureParameterExp   = regexp.MustCompile(`\$[0-9]+`)
+	stepCountParameterExp = regexp.MustCompile(`\$STEP_COUNT`)
+	stepParameterExp      = regexp.MustCompile(`\$STEP`)
+)
+
+// Template is a destination path template. $1, $2, ... are replaced by the
+// groups captured from the source path, $STEP by the current step and
+// $STEP_COUNT by the position within that step. Each of them can also be
+// written in braces, ${1}, ${STEP} or ${STEP_COUNT}, optionally with a width
+// to pad numbers with zeros to: ${1:2} turns a captured 5 into 05.
+type Template struct {
+	template string
+}
+
+func NewTemplate(template string) Template {
+	return Template{template: template}
+}
+
+func (t Template) String() string {
+	return t.template
+}
+
+// Root returns the deepest directory of the template that doesn't depend on
+// any parameter.
+func (t Template) Root() string {
+	template := t.template
+	if i := strings.Index(template, "$"); i >= 0 {
+		template = template[:i]
+	}
+	return filepath.Dir(template)
+}
+
+// Fill returns the destination path for the given captures. A step of zero
+// means steps are not in use, and leaves $STEP and $STEP_COUNT as they are.
+func (t Template) Fill(captures []string, step, stepCount int) (string, error) {
+	slog.Debug("Filling parameters for destination path", "template", t.template, "captures", captures)
+
+	var destination strings.Builder
+	for _, token := range t.Tokens() {
+		switch token.Kind {
+		case CaptureToken:
+			if token.Index < 1 || token.Index > len(captures) {
+				return "", fmt.Errorf("template uses %s, but the source pattern captures %d groups", token.Text, len(captures))
+			}
+			destination.WriteString(pad(captures[token.Index-1], token.Width))
+		case StepToken:
+			if step == 0 {
+				destination.WriteString(token.Text)
+				continue
+			}
+			slog.Debug("Filling step parameter", "step", step)
+			destination.WriteString(pad(strconv.Itoa(step), token.Width))
+		case StepCountToken:
+			if step == 0 {
+				destination.WriteString(token.Text)
+				continue
+			}
+			slog.Debug("Filling step count parameter", "step_count", stepCount)
+			destination.WriteString(pad(strconv.Itoa(stepCount), token.Width))
+		default:
+			destination.WriteString(token.Text)
+		}
+	}
+
+	return destination.String(), nil
+}
+
+// pad writes value, when it is a number, with at least width digits. Other
+// values, and numbers when width is zero, are kept as they are.
+func pad(value string, width int) string {
+	if width == 0 {
+		return value
+	}
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return value
+	}
+	return fmt.Sprintf("%0*d", width, number)
+}
+
+// Kinds of template tokens.
+const (
+	TextToken      = "text"
+	CaptureToken   = "capture"
+	StepToken      = "step"
+	StepCountToken = "step_count"
+)
+
+// TemplateToken is a piece of a template: literal text, or a parameter.
+// Index is the group number of capture tokens, and Width the number of digits
+// parameters are padded to, zero when they aren't.
+type TemplateToken struct {
+	Kind  string
+	Text  string
+	Index int
+	Width int
+}
+
+// Tokens splits the template into the pieces Fill sees. Braced parameters are
+// read first, then captures, with as many digits as follow the $, then
+// $STEP_COUNT, then $STEP.
+func (t Template) Tokens() []TemplateToken {
+	tokens := make([]TemplateToken, 0)
+
+	text := func(s string) {
+		for i, part := range splitAround(s, stepCountParameterExp) {
+			if i%2 == 1 {
+				tokens = append(tokens, TemplateToken{Kind: StepCountToken, Text: part})
+				continue
+			}
+			for j, part := range splitAround(part, stepParameterExp) {
+				if j%2 == 1 {
+					tokens = append(tokens, TemplateToken{Kind: StepToken, Text: part})
+				} else if part != "" {
+					tokens = append(tokens, TemplateToken{Kind: TextToken, Text: part})
+				}
+			}
+		}
+	}
+
+	unbraced := func(s string) {
+		for i, part := range splitAround(s, captureParameterExp) {
+			if i%2 == 1 {
+				index, _ := strconv.Atoi(part[1:])
+				tokens = append(tokens, TemplateToken{Kind: CaptureToken, Text: part, Index: index})
+			} else {
+				text(part)
+			}
+		}
+	}
+
+	for i, part := range splitAround(t.template, bracedParameterExp) {
+		if i%2 == 0 {
+			unbraced(part)
+			continue
+		}
+
+		match := bracedParameterExp.FindStringSubmatch(part)
+		token := TemplateToken{Text: part}
+		if match[2] != "" {
+			token.Width, _ = strconv.Atoi(match[2][1:])
+		}
+		switch match[1] {
+		case "STEP":
+			token.Kind = StepToken
+		case "STEP_COUNT":
+			token.Kind = StepCountToken
+		default:
+			token.Kind = CaptureToken
+			token.Index, _ = strconv.Atoi(match[1])
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+// splitAround splits s into the text between matches of exp and the matches
+// themselves, alternating and starting with text.
+func splitAround(s string, exp *regexp.Regexp) []string {
+	parts := make([]string, 0)
+	last := 0
+	for _, match := range exp.FindAllStringIndex(s, -1) {
+		parts = append(parts, s[last:match[0]], s[match[0]:match[1]])
+		last = match[1]
+	}
+	return append(parts, s[last:])
+}
@@ -0,0 +1,104 @@
+package link
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTemplateTokens(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []TemplateToken
+	}{
+		{
+			template: "/library/$1/E$2.mkv",
+			want: []TemplateToken{
+				{Kind: TextToken, Text: "/library/"},
+				{Kind: CaptureToken, Text: "$1", Index: 1},
+				{Kind: TextToken, Text: "/E"},
+				{Kind: CaptureToken, Text: "$2", Index: 2},
+				{Kind: TextToken, Text: ".mkv"},
+			},
+		},
+		{
+			template: "S$STEPE$STEP_COUNT $15",
+			want: []TemplateToken{
+				{Kind: TextToken, Text: "S"},
+				{Kind: StepToken, Text: "$STEP"},
+				{Kind: TextToken, Text: "E"},
+				{Kind: StepCountToken, Text: "$STEP_COUNT"},
+				{Kind: TextToken, Text: " "},
+				{Kind: CaptureToken, Text: "$15", Index: 15},
+			},
+		},
+		{
+			template: "${1}5 ${2:3} ${STEP:2}x${STEP_COUNT}",
+			want: []TemplateToken{
+				{Kind: CaptureToken, Text: "${1}", Index: 1},
+				{Kind: TextToken, Text: "5 "},
+				{Kind: CaptureToken, Text: "${2:3}", Index: 2, Width: 3},
+				{Kind: TextToken, Text: " "},
+				{Kind: StepToken, Text: "${STEP:2}", Width: 2},
+				{Kind: TextToken, Text: "x"},
+				{Kind: StepCountToken, Text: "${STEP_COUNT}"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if got := NewTemplate(test.template).Tokens(); !slices.Equal(got, test.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.template, got, test.want)
+		}
+	}
+}
+
+func TestTemplateFill(t *testing.T) {
+	tests := []struct {
+		template  string
+		captures  []string
+		step      int
+		stepCount int
+		want      string
+		wantErr   bool
+	}{
+		{template: "/library/$1 E$2.mkv", captures: []string{"Show", "5"}, want: "/library/Show E5.mkv"},
+		{template: "/library/$1 E${2:2}.mkv", captures: []string{"Show", "5"}, want: "/library/Show E05.mkv"},
+		{template: "/library/E${1:2}.mkv", captures: []string{"123"}, want: "/library/E123.mkv"},
+		{template: "/library/E${1:2}.mkv", captures: []string{"007"}, want: "/library/E07.mkv"},
+		{template: "/library/${1:2}.mkv", captures: []string{"Show"}, want: "/library/Show.mkv"},
+		{template: "/library/${1}5.mkv", captures: []string{"Show"}, want: "/library/Show5.mkv"},
+		{template: "/S${STEP:2}E${STEP_COUNT:3}", step: 2, stepCount: 7, want: "/S02E007"},
+		{template: "/S$STEPE$STEP_COUNT", step: 2, stepCount: 7, want: "/S2E7"},
+		{template: "/S$STEPE$STEP_COUNT", want: "/S$STEPE$STEP_COUNT"},
+		{template: "/library/$2.mkv", captures: []string{"Show"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := NewTemplate(test.template).Fill(test.captures, test.step, test.stepCount)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want one: %v", test.template, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+func TestTemplateRoot(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"/library/Show/Season $STEP/$1.mkv", "/library/Show"},
+		{"/library/Show/Show E$1.mkv", "/library/Show"},
+		{"/library/Show/$1.mkv", "/library/Show"},
+		{"$1/E$2.mkv", "."},
+	}
+
+	for _, test := range tests {
+		if got := NewTemplate(test.template).Root(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.template, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	TextLogFormat = "text"
+	JSONLogFormat = "json"
+)
+
+// configureLogging sets up the default slog logger from the logging flags.
+// Logs go to stderr, or to the log file, so they never mix with the output.
+// Without -v or -q only warnings are logged, except in watch mode where every
+// action is logged since nobody is looking at the output.
+func configureLogging(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+
+	verbosity, err := flags.GetCount(VerboseFlag)
+	if err != nil {
+		return err
+	}
+	quiet, err := flags.GetBool(QuietFlag)
+	if err != nil {
+		return err
+	}
+	if quiet && verbosity > 0 {
+		return fmt.Errorf("--%s and --%s cannot be used together", QuietFlag, VerboseFlag)
+	}
+
+	level := slog.LevelWarn
+	if cmd == watchCmd {
+		level = slog.LevelInfo
+	}
+	switch {
+	case quiet:
+		level = slog.LevelError
+	case verbosity == 1:
+		level = slog.LevelInfo
+	case verbosity > 1:
+		level = slog.LevelDebug
+	}
+
+	var output io.Writer = os.Stderr
+	if logFile := flags.Lookup(LogFileFlag).Value.String(); logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		output = file
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch logFormat := flags.Lookup(LogFormatFlag).Value.String(); logFormat {
+	case TextLogFormat:
+		slog.SetDefault(slog.New(slog.NewTextHandler(output, options)))
+	case JSONLogFormat:
+		slog.SetDefault(slog.New(slog.NewJSONHandler(output, options)))
+	default:
+		return fmt.Errorf("invalid log format: %s (expected %s or %s)", logFormat, TextLogFormat, JSONLogFormat)
+	}
+
+	return nil
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
-	"path"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"supalink/link"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
@@ -21,324 +25,576 @@ import (
 // objective: supalink src/path/.*S([0-9]{2})E([0-9]{2}).*.mkv -r destination/path/Season\ $STEP/Name (Year) S$1E$2.mkv
 
 const (
-	VerboseFlag      = "verbose"
-	VerboseFlagShort = "v"
-	ConfirmFlag      = "confirm"
-	ConfirmFlagShort = "c"
-	StepFlag         = "step"
-	StepFlagShort    = "s"
-	DryRunFlag       = "dry-run"
-	DryRunFlagShort  = "d"
-	FormatFlag       = "format"
-	FormatFlagShort  = "f"
+	VerboseFlag          = "verbose"
+	VerboseFlagShort     = "v"
+	QuietFlag            = "quiet"
+	QuietFlagShort       = "q"
+	LogFormatFlag        = "log-format"
+	LogFileFlag          = "log-file"
+	RegexEngineFlag      = "regex-engine"
+	RegexTimeoutFlag     = "regex-timeout"
+	DestRootFlag         = "dest-root"
+	AllowSeparatorsFlag  = "allow-separators"
+	ConfirmFlag          = "confirm"
+	ConfirmFlagShort     = "c"
+	ReviewFlag           = "review"
+	ReviewFlagShort      = "r"
+	StepFlag             = "step"
+	StepFlagShort        = "s"
+	DryRunFlag           = "dry-run"
+	DryRunFlagShort      = "d"
+	ReplaceFlag          = "replace"
+	AtomicFlag           = "atomic"
+	StagedFlag           = "staged"
+	NoTruncateFlag       = "no-truncate"
+	ColorFlag            = "color"
+	ThemeFlag            = "theme"
+	FormatFlag           = "format"
+	FormatFlagShort      = "f"
+	SettleFlag           = "settle"
+	IncrementalFlag      = "incremental"
+	IncrementalFlagShort = "i"
+	ManifestFlag         = "manifest"
+	ManifestFlagShort    = "m"
+	Print0Flag           = "print0"
+	FromStdinFlag        = "from-stdin"
+	FromStdinFlagShort   = "0"
 )
 
 const (
-	TreeFormat  = "tree"
-	TableFormat = "table"
+	TreeFormat   = "tree"
+	TableFormat  = "table"
+	JSONFormat   = "json"
+	NDJSONFormat = "ndjson"
+	CSVFormat    = "csv"
+	TSVFormat    = "tsv"
+	LinesFormat  = "lines"
+	DiffFormat   = "diff"
 )
 
-const regexConstants = ".*+?[]()|{}"
+var formats = []string{TreeFormat, TableFormat, DiffFormat, JSONFormat, NDJSONFormat, CSVFormat, TSVFormat, LinesFormat}
+
+const (
+	ConfirmAll  = "all"
+	ConfirmEach = "each"
+)
 
 type settings struct {
-	Verbose bool
-	Confirm bool
-	DryRun  bool
-	Steps   []int
-	Format  string
+	Confirm      bool
+	ConfirmEach  bool
+	Review       bool
+	DryRun       bool
+	Replace      bool
+	Atomic       bool
+	Staged       bool
+	NoTruncate   bool
+	Incremental  bool
+	FromStdin    bool
+	Print0       bool
+	Steps        []int
+	Format       string
+	Manifest     string
+	RegexEngine  string
+	RegexTimeout time.Duration
+	// DestRoots are the directories destinations have to stay within. The
+	// template root when empty.
+	DestRoots       []string
+	AllowSeparators bool
+	// Snapshot is the tree recorded by `supalink snapshot` that the run
+	// works on instead of the real filesystem, when --from-snapshot is set.
+	Snapshot *link.MemoryFS
 }
 
-type stepManager struct {
-	currentStep      int
-	currentStepCount int
+// roots returns the directories destinations made from a template have to
+// stay within: the ones given with --dest-root, or else the template root.
+func (s settings) roots(destinationRoot string) []string {
+	if len(s.DestRoots) > 0 {
+		return s.DestRoots
+	}
+	return []string{destinationRoot}
 }
 
-func (sm *stepManager) NextStep(settings settings) (int, int, error) {
-	if len(settings.Steps) == 0 {
-		return 0, 0, fmt.Errorf("no steps defined")
-	}
+// replanSymlinks plans the symlinks again after the user changed them, and
+// refuses edited destinations that left the allowed roots. Callers of
+// linkAndRecord set the roots before anything is shown to the user.
+func replanSymlinks(symlinks link.Plan, settings settings) {
+	symlinks.Replan(settings.linker(), settings.Replace)
+	symlinks.Confine(settings.DestRoots)
+}
 
-	if sm.currentStep == 0 {
-		sm.currentStep = 1
-		sm.currentStepCount = 1
-		return sm.currentStep, sm.currentStepCount, nil
+// newPlanner compiles the source pattern with the regex engine of the
+// settings.
+func newPlanner(settings settings, pattern, template string) (*link.Planner, error) {
+	planner, err := link.NewPlannerWithEngine(pattern, template, settings.RegexEngine, settings.RegexTimeout)
+	if err != nil {
+		return nil, err
 	}
+	planner.Roots = settings.DestRoots
+	planner.AllowSeparators = settings.AllowSeparators
+	return planner, nil
+}
 
-	if sm.currentStepCount >= settings.Steps[sm.currentStep-1] {
-		if sm.currentStep >= len(settings.Steps) {
-			return 0, 0, fmt.Errorf("exceeded the number of defined steps")
-		}
-		sm.currentStep++
-		sm.currentStepCount = 1
-	} else {
-		sm.currentStepCount++
+// linker returns the filesystem symlinks are checked and created in.
+func (s settings) linker() link.Swapper {
+	if s.Snapshot != nil {
+		return s.Snapshot
 	}
+	return link.OS
+}
 
-	return sm.currentStep, sm.currentStepCount, nil
+// filesystem returns the filesystem source and destination trees are read
+// from.
+func (s settings) filesystem() fs.FS {
+	if s.Snapshot != nil {
+		return s.Snapshot
+	}
+	return link.OS
 }
 
 const (
 	accentColor    = lipgloss.Color("3")
 	whiteColor     = lipgloss.Color("255")
 	lightGrayColor = lipgloss.Color("245")
+	conflictColor  = lipgloss.Color("1")
+	addedColor     = lipgloss.Color("2")
 )
 
 var rootCmd = &cobra.Command{
-	Use:  "supalink <source path regex> <destination path template>",
-	Args: cobra.ExactArgs(2),
+	Use: "supalink <source path regex> <destination path template>",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed(ExampleFlag) {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags and arguments are valid by now, any later error isn't a
+		// usage problem.
+		cmd.SilenceUsage = true
+		if err := configureLogging(cmd); err != nil {
+			return err
+		}
+		return configureStyles(cmd.Flags())
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		srcPath := args[0]
-		destPath := args[1]
-
 		settings, err := getSettings(cmd.Flags())
 		if err != nil {
 			return err
 		}
 
-		addStopSuffixToPattern(&srcPath)
-
-		matchingPathsAndDestinations := getMatchingPathsAndDestinations(srcPath, destPath, settings)
+		if cmd.Flags().Changed(ExampleFlag) {
+			args, err = argumentsFromExamples(cmd, &settings)
+			if err != nil {
+				return err
+			}
+		}
 
-		if len(matchingPathsAndDestinations) == 0 {
-			fmt.Println("No matching paths found.")
-			return nil
+		planner, err := newPlanner(settings, args[0], args[1])
+		if err != nil {
+			return err
 		}
 
-		createSymlinks(matchingPathsAndDestinations, settings)
+		symlinks, manifestPath, previousManifest, err := matchPaths(cmd.Context(), planner, settings)
+		if err != nil {
+			return err
+		}
 
-		return nil
+		settings.DestRoots = settings.roots(planner.Template.Root())
+		return linkAndRecord(cmd.Context(), symlinks, settings, planner.Template.Root(), manifestPath, previousManifest)
 	},
 }
 
-func getSettings(flags *pflag.FlagSet) (settings, error) {
-	settings := settings{
-		Verbose: flags.Changed(VerboseFlag) && flags.Lookup(VerboseFlag).Value.String() == "true",
-		Confirm: flags.Changed(ConfirmFlag) && flags.Lookup(ConfirmFlag).Value.String() == "true",
-		DryRun:  flags.Changed(DryRunFlag) && flags.Lookup(DryRunFlag).Value.String() == "true",
-		Format:  flags.Lookup(FormatFlag).Value.String(),
-		Steps:   make([]int, 0),
-	}
-	stepsAsStringArray, err := flags.GetStringArray(StepFlag)
+// matchPaths runs matching and templating: it finds the source paths, on disk
+// or from stdin, and plans a symlink for every one that matches. It also
+// returns where the manifest lives and what a previous run recorded there.
+func matchPaths(ctx context.Context, planner *link.Planner, settings settings) (link.Plan, string, *manifest, error) {
+	srcPath := planner.Pattern.String()
+	destPath := planner.Template.String()
+
+	manifestPath, previousManifest, err := loadManifest(settings, defaultManifestPath(destPath), srcPath, destPath)
 	if err != nil {
-		return settings, err
+		return nil, "", nil, err
 	}
 
-	for _, stepAsString := range stepsAsStringArray {
-		step, err := strconv.Atoi(stepAsString)
+	planner.Replace = settings.Replace
+	if settings.Snapshot != nil {
+		planner.FS, planner.Linker = settings.Snapshot, settings.Snapshot
+	}
+	planner.Linked = previousManifest.sources()
+	if len(settings.Steps) > 0 {
+		planner.Steps = previousManifest.stepCounter(settings.Steps)
+	}
+
+	var paths []string
+	if settings.FromStdin {
+		paths, err = readNullSeparatedPaths(os.Stdin)
 		if err != nil {
-			return settings, fmt.Errorf("invalid step value: %s", stepAsString)
+			return nil, "", nil, err
+		}
+		slog.Info("Read paths from stdin", "count", len(paths))
+	} else {
+		paths, err = planner.Walk(ctx)
+		if err != nil {
+			return nil, "", nil, err
 		}
-		settings.Steps = append(settings.Steps, step)
 	}
 
-	return settings, err
+	symlinks, err := planner.Plan(ctx, paths)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return symlinks, manifestPath, previousManifest, nil
 }
 
-func addStopSuffixToPattern(pattern *string) {
-	if !strings.HasSuffix(*pattern, "$") {
-		*pattern += "$"
+// linkAndRecord runs the part of the flow shared by every way of planning
+// symlinks: collision checks, preview, confirmation, linking and the manifest.
+// The returned error carries the exit code matching how the run went.
+func linkAndRecord(ctx context.Context, symlinks link.Plan, settings settings, destinationRoot, manifestPath string, previousManifest *manifest) error {
+	if len(symlinks) == 0 {
+		if settings.Incremental {
+			printStatus(settings, "No new matching paths found.\n")
+		} else {
+			printStatus(settings, "No matching paths found.\n")
+		}
+		// Scripts still get something to parse: an empty JSON array, or
+		// the header row of CSV and TSV.
+		if isMachineReadableFormat(settings.Format) {
+			printSymlinks(symlinks, settings)
+		}
+		return exitWith(ExitNoMatches, "no matching paths found")
 	}
-}
 
-func getMatchingPathsAndDestinations(srcPath, destPath string, settings settings) map[string]string {
-	matchingPathsAndDestinations := make(map[string]string)
+	// Staging into the working directory or the filesystem root can't work,
+	// which is better said before the preview.
+	if settings.Staged {
+		if _, err := link.StagingRoot(destinationRoot); err != nil {
+			return err
+		}
+	}
 
-	rootDirectory := findRootDirectory(srcPath)
-	printIfVerbose(settings, "Searching in root directory: %s\n", rootDirectory)
+	symlinks.Confine(settings.DestRoots)
+	symlinks.MarkCollisions()
 
-	srcExp := regexp.MustCompile(srcPath)
+	attempted, err := createSymlinks(ctx, symlinks, settings, destinationRoot)
+	if !attempted {
+		return outcomeOf(symlinks)
+	}
+	// An applier failing before any symlink was tried leaves nothing to
+	// record, and no result to tell the outcome from.
+	if err != nil && !hasResults(symlinks) {
+		return err
+	}
 
-	stepManager := &stepManager{}
+	if settings.Snapshot != nil {
+		printStatus(settings, "Ran against a snapshot, nothing was changed on disk.\n")
+		return outcomeOf(symlinks)
+	}
 
-	filepath.Walk(rootDirectory, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
+	// When symlinks failed, the manifest often fails for the same reason, and
+	// the exit code should still say how the symlinks went.
+	outcome := outcomeOf(symlinks)
+	if err := writeManifest(manifestPath, previousManifest.with(symlinks)); err != nil {
+		if outcome == nil {
 			return err
 		}
+		printStatus(settings, "%v\n", err)
+	}
 
-		if matches := srcExp.FindStringSubmatch(path); matches != nil {
-			printIfVerbose(settings, "Path matched: %s\n", path)
-			parameterMatches := matches[1:]
-			destPathWithFilledParameters := getDestPathWithFilledParameters(destPath, parameterMatches, settings, stepManager)
-			matchingPathsAndDestinations[path] = destPathWithFilledParameters
-			return nil
-		}
-
-		return nil
-	})
-
-	return matchingPathsAndDestinations
+	return outcome
 }
 
-func printIfVerbose(settings settings, message string, args ...any) {
-	if settings.Verbose {
-		fmt.Printf(message, args...)
+func getSettings(flags *pflag.FlagSet) (settings, error) {
+	settings := settings{
+		Review:     flags.Changed(ReviewFlag) && flags.Lookup(ReviewFlag).Value.String() == "true",
+		Replace:    flags.Changed(ReplaceFlag) && flags.Lookup(ReplaceFlag).Value.String() == "true",
+		NoTruncate: flags.Changed(NoTruncateFlag) && flags.Lookup(NoTruncateFlag).Value.String() == "true",
+		DryRun:     flags.Changed(DryRunFlag) && flags.Lookup(DryRunFlag).Value.String() == "true",
+		Format:     flags.Lookup(FormatFlag).Value.String(),
+		Print0:     flags.Changed(Print0Flag) && flags.Lookup(Print0Flag).Value.String() == "true",
+		Steps:      make([]int, 0),
 	}
-}
-
-func findRootDirectory(path string) string {
-	for i, c := range path {
-		if strings.ContainsRune(regexConstants, c) {
-			return filepath.Dir(path[:i])
+	switch confirm := flags.Lookup(ConfirmFlag).Value.String(); confirm {
+	case "", "false":
+	case ConfirmAll, "true":
+		settings.Confirm = true
+	case ConfirmEach:
+		settings.Confirm = true
+		settings.ConfirmEach = true
+	default:
+		return settings, fmt.Errorf("invalid confirm value: %s (expected %s or %s)", confirm, ConfirmAll, ConfirmEach)
+	}
+	if settings.Print0 {
+		if flags.Changed(FormatFlag) && settings.Format != LinesFormat {
+			return settings, fmt.Errorf("--%s can only be used with the %s format", Print0Flag, LinesFormat)
 		}
+		settings.Format = LinesFormat
 	}
-	return filepath.Dir(path)
-}
-
-func getDestPathWithFilledParameters(destPath string, parameterMatches []string, settings settings, stepManager *stepManager) string {
-	printIfVerbose(settings, "Filling parameters for destination path: %s\n", destPath)
-	printIfVerbose(settings, "Parameter matches: %v\n", parameterMatches)
-
-	parameterExp := regexp.MustCompile(`\$[0-9]+`)
-	destPathWithFilledParameters := parameterExp.ReplaceAllStringFunc(destPath, func(s string) string {
-		index, err := strconv.Atoi(s[1:])
+	settings.RegexEngine = flags.Lookup(RegexEngineFlag).Value.String()
+	if !slices.Contains(link.Engines, settings.RegexEngine) {
+		return settings, fmt.Errorf("invalid regex engine: %s (expected one of %s)", settings.RegexEngine, strings.Join(link.Engines, ", "))
+	}
+	timeout, err := flags.GetDuration(RegexTimeoutFlag)
+	if err != nil {
+		return settings, err
+	}
+	settings.RegexTimeout = timeout
+	if settings.RegexTimeout <= 0 {
+		return settings, fmt.Errorf("invalid regex timeout: %s", settings.RegexTimeout)
+	}
+	destRoots, err := flags.GetStringArray(DestRootFlag)
+	if err != nil {
+		return settings, err
+	}
+	for _, root := range destRoots {
+		if root == "" {
+			return settings, fmt.Errorf("--%s can't be empty", DestRootFlag)
+		}
+		settings.DestRoots = append(settings.DestRoots, filepath.Clean(root))
+	}
+	settings.AllowSeparators = flags.Lookup(AllowSeparatorsFlag).Value.String() == "true"
+	if !slices.Contains(formats, settings.Format) {
+		return settings, fmt.Errorf("invalid format: %s (expected one of %s)", settings.Format, strings.Join(formats, ", "))
+	}
+	if flags.Lookup(AtomicFlag) != nil {
+		settings.Atomic = flags.Changed(AtomicFlag) && flags.Lookup(AtomicFlag).Value.String() == "true"
+	}
+	if flags.Lookup(IncrementalFlag) != nil {
+		settings.Incremental = flags.Changed(IncrementalFlag) && flags.Lookup(IncrementalFlag).Value.String() == "true"
+		settings.Manifest = flags.Lookup(ManifestFlag).Value.String()
+	}
+	if flags.Lookup(StagedFlag) != nil {
+		settings.Staged = flags.Changed(StagedFlag) && flags.Lookup(StagedFlag).Value.String() == "true"
+		if settings.Staged && settings.Incremental {
+			return settings, fmt.Errorf("--%s rebuilds the whole destination tree and cannot be used with --%s", StagedFlag, IncrementalFlag)
+		}
+	}
+	if flags.Lookup(FromStdinFlag) != nil {
+		settings.FromStdin = flags.Changed(FromStdinFlag) && flags.Lookup(FromStdinFlag).Value.String() == "true"
+	}
+	if flags.Lookup(FromSnapshotFlag) != nil && flags.Changed(FromSnapshotFlag) {
+		if settings.Incremental {
+			return settings, fmt.Errorf("--%s cannot be used with --%s, runs against a snapshot don't record a manifest", FromSnapshotFlag, IncrementalFlag)
+		}
+		if settings.FromStdin {
+			return settings, fmt.Errorf("--%s cannot be used with --%s", FromSnapshotFlag, FromStdinFlag)
+		}
+		snapshot, err := loadSnapshot(flags.Lookup(FromSnapshotFlag).Value.String())
 		if err != nil {
-			return s
+			return settings, err
 		}
-		return parameterMatches[index-1]
-	})
-
-	if len(settings.Steps) == 0 {
-		return destPathWithFilledParameters
+		settings.Snapshot = snapshot
 	}
-
-	step, stepCount, err := stepManager.NextStep(settings)
+	stepsAsStringArray, err := flags.GetStringArray(StepFlag)
 	if err != nil {
-		printIfVerbose(settings, "Error getting next step: %v\n", err)
+		return settings, err
 	}
 
-	stepCountParameterExp := regexp.MustCompile(`\$STEP_COUNT`)
-	destPathWithFilledParameters = stepCountParameterExp.ReplaceAllStringFunc(destPathWithFilledParameters, func(s string) string {
-		printIfVerbose(settings, "Filling step count parameter: %d\n", stepCount)
-		return strconv.Itoa(stepCount)
-	})
-
-	stepParameterExp := regexp.MustCompile(`\$STEP`)
-	destPathWithFilledParameters = stepParameterExp.ReplaceAllStringFunc(destPathWithFilledParameters, func(s string) string {
-		printIfVerbose(settings, "Filling step parameter: %d\n", step)
-		return strconv.Itoa(step)
-	})
+	for _, stepAsString := range stepsAsStringArray {
+		step, err := strconv.Atoi(stepAsString)
+		if err != nil {
+			return settings, fmt.Errorf("invalid step value: %s", stepAsString)
+		}
+		settings.Steps = append(settings.Steps, step)
+	}
 
-	return destPathWithFilledParameters
+	return settings, err
 }
 
-func createSymlinks(matchingPathsAndDestinations map[string]string, settings settings) {
-	printSymlinks(matchingPathsAndDestinations, settings)
+// createSymlinks prints the symlinks and creates them, unless this is a dry
+// run or the user cancels. It reports whether anything was attempted, and
+// returns the error of the applier.
+// Machine-readable formats are printed once every result is known. The others
+// are printed up front as a preview when the user has to look at the plan,
+// and again with the results once the symlinks are created. The diff can only
+// be shown before, so it is always printed up front.
+func createSymlinks(ctx context.Context, symlinks link.Plan, settings settings, destinationRoot string) (bool, error) {
+	if isMachineReadableFormat(settings.Format) {
+		defer printSymlinks(symlinks, settings)
+	} else if settings.DryRun || settings.Confirm || settings.Review || settings.Format == DiffFormat {
+		printSymlinks(symlinks, settings)
+	}
 
 	if settings.DryRun {
-		fmt.Println("Dry run enabled, no symlinks will be created.")
-		return
+		symlinks.SetResults(link.DryRunResult)
+		printStatus(settings, "Dry run enabled, no symlinks will be created.\n")
+		return false, nil
 	}
 
-	if settings.Confirm {
+	if settings.Review {
+		accepted, err := reviewSymlinks(symlinks, settings)
+		if err != nil {
+			printStatus(settings, "%v\n", err)
+		}
+		if !accepted {
+			symlinks.SetResults(link.CancelledResult)
+			printStatus(settings, "Operation cancelled by user.\n")
+			return false, nil
+		}
+	} else if settings.ConfirmEach {
+		confirmEachSymlink(symlinks, settings)
+	} else if settings.Confirm {
 		var response string
-		fmt.Print("Are you sure you want to create these symlinks? (y/n): ")
-		fmt.Scanln(&response)
+		printStatus(settings, "Are you sure you want to create these symlinks? (y/n): ")
+		input := confirmationInput(settings)
+		fmt.Fscanln(input, &response)
+		input.Close()
 		if strings.ToLower(response) != "y" {
-			fmt.Println("Operation cancelled by user.")
-			return
+			symlinks.SetResults(link.CancelledResult)
+			printStatus(settings, "Operation cancelled by user.\n")
+			return false, nil
 		}
 	}
 
-	for source, destination := range matchingPathsAndDestinations {
-		os.MkdirAll(filepath.Dir(destination), os.ModePerm)
-		err := os.Symlink(source, destination)
-		if err != nil {
-			fmt.Printf("Failed to create symlink: %s -> %s. Error: %v\n", source, destination, err)
-		} else {
-			printIfVerbose(settings, "Symlink created: %s -> %s\n", source, destination)
-		}
+	start := time.Now()
+	applier := &link.Applier{Atomic: settings.Atomic, Linker: settings.linker()}
+	var err error
+	if settings.Staged {
+		err = applier.Stage(ctx, symlinks, destinationRoot)
+	} else {
+		err = applier.Apply(ctx, symlinks)
+	}
+	logResults(symlinks)
+
+	if !isMachineReadableFormat(settings.Format) {
+		printSymlinks(symlinks, settings)
+		printSummary(symlinks, settings, time.Since(start))
+	}
+	if err != nil && hasResults(symlinks) {
+		printStatus(settings, "%v\n", err)
 	}
+
+	return true, err
 }
 
-func printSymlinks(matchingPathsAndDestinations map[string]string, settings settings) {
-	printIfVerbose(settings, "Preparing to print symlinks in format: %s\n", settings.Format)
-	switch settings.Format {
+// printSymlinks prints the symlinks in the configured format. Once results are
+// known, the tree and table formats also show the status of every symlink,
+// and the diff format falls back to the table.
+func printSymlinks(symlinks link.Plan, settings settings) {
+	slog.Debug("Preparing to print symlinks", "format", settings.Format)
+
+	showResults := hasResults(symlinks)
+	format := settings.Format
+	if showResults && format == DiffFormat {
+		format = TableFormat
+	}
+
+	switch format {
 	case TreeFormat:
-		sourcePaths := make([]string, 0, len(matchingPathsAndDestinations))
-		destinationPaths := make([]string, 0, len(matchingPathsAndDestinations))
-		for source, destination := range matchingPathsAndDestinations {
-			sourcePaths = append(sourcePaths, source)
-			destinationPaths = append(destinationPaths, destination)
+		sourcePaths := make([]string, 0, len(symlinks))
+		destinationPaths := make([]string, 0, len(symlinks))
+		for _, symlink := range symlinks {
+			sourcePaths = append(sourcePaths, symlink.Source)
+			destinationPaths = append(destinationPaths, symlink.Destination)
 		}
 
-		sourceTree := createTree(sourcePaths).toLipglossTree()
-		destinationTree := createTree(destinationPaths).toLipglossTree()
+		width := columnWidth(settings, 2)
+		sourceTree := createTree(sourcePaths).toLipglossTree(width)
+		destinationNode := createTree(destinationPaths)
+		if showResults {
+			destinationNode.mark(destinationNode.value, resultMarkers(symlinks))
+		}
+		destinationTree := destinationNode.toLipglossTree(width)
 
 		table := table.
 			New().
 			Headers("Source", "Destination").
 			Border(lipgloss.RoundedBorder()).
-			BorderStyle(lipgloss.NewStyle().Foreground(accentColor)).
+			BorderStyle(styles.Border).
 			StyleFunc(func(row, col int) lipgloss.Style {
 				style := lipgloss.NewStyle().Padding(0, 1)
 				if row == table.HeaderRow {
-					style = style.Bold(true).Foreground(accentColor)
+					style = style.Inherit(styles.Header)
 				}
 
 				return style
 			}).
 			Rows([]string{fmt.Sprintln(sourceTree), fmt.Sprintln(destinationTree)})
 		fmt.Println(table)
+
+		if showResults {
+			for _, symlink := range symlinks {
+				if symlink.Result == link.FailedResult {
+					fmt.Println(styles.Error.Render(fmt.Sprintf("Failed: %s: %s", symlink.Destination, symlink.Error)))
+				}
+			}
+		}
 	case TableFormat:
+		allPaths := make([]string, 0)
+		for _, symlink := range symlinks {
+			allPaths = append(allPaths, symlink.Source, symlink.Destination)
+		}
+		rootDirectory := findRootDirectoryOfAllPaths(allPaths)
+
+		slog.Debug("All paths contain root directory", "root", rootDirectory)
+
+		orderedSymlinks := slices.Clone(symlinks)
+		sort.Slice(orderedSymlinks, func(i, j int) bool {
+			return orderedSymlinks[i].Source < orderedSymlinks[j].Source
+		})
+
+		headers := []string{"Source", "Destination"}
+		if showResults {
+			headers = append(headers, "Status")
+		}
+
 		table := table.
 			New().
-			Headers("Source", "Destination").
+			Headers(headers...).
 			Border(lipgloss.RoundedBorder()).
-			BorderStyle(lipgloss.NewStyle().Foreground(accentColor)).
+			BorderStyle(styles.Border).
 			StyleFunc(func(row, col int) lipgloss.Style {
 				style := lipgloss.NewStyle().Padding(0, 1)
 
 				if row == table.HeaderRow {
-					style = style.Bold(true).Foreground(accentColor)
-					return style
+					return style.Inherit(styles.Header)
+				}
+
+				if col == 2 {
+					return style.Inherit(resultStyle(orderedSymlinks[row].Result))
+				}
+
+				switch orderedSymlinks[row].Action {
+				case link.ConflictAction, link.RefuseAction:
+					return style.Inherit(styles.Conflict)
+				case link.SkipAction, link.ExcludeAction:
+					return style.Inherit(styles.Skipped)
 				}
 
 				if row%2 == 0 {
-					style = style.Foreground(lightGrayColor)
+					style = style.Inherit(styles.Row)
 				} else {
-					style = style.Foreground(whiteColor)
+					style = style.Inherit(styles.AlternateRow)
 				}
 				return style
 			})
 
-		allPaths := make([]string, 0)
-		for source, destination := range matchingPathsAndDestinations {
-			allPaths = append(allPaths, source, destination)
-		}
-		rootDirectory := findRootDirectoryOfAllPaths(allPaths)
-
-		printIfVerbose(settings, "All paths contain root directory: %s\n", rootDirectory)
-
-		orderedMatchingPathsAndDestinations := make(map[string]string, 0)
-		orderedSources := make([]string, 0)
-
-		for source := range matchingPathsAndDestinations {
-			orderedSources = append(orderedSources, source)
-		}
-
-		sort.Strings(orderedSources)
-		for _, source := range orderedSources {
-			orderedMatchingPathsAndDestinations[source] = matchingPathsAndDestinations[source]
-		}
+		width := columnWidth(settings, len(headers))
+		for _, symlink := range orderedSymlinks {
+			source := strings.TrimPrefix(symlink.Source, rootDirectory+string(os.PathSeparator))
+			destination := strings.TrimPrefix(symlink.Destination, rootDirectory+string(os.PathSeparator))
 
-		for source, destination := range orderedMatchingPathsAndDestinations {
-			source = strings.TrimPrefix(source, rootDirectory+string(os.PathSeparator))
-			destination = strings.TrimPrefix(destination, rootDirectory+string(os.PathSeparator))
-
-			if len(source) > 45 {
-				extension := path.Ext(source)
-				source = source[:40] + "(...)" + extension
+			row := []string{truncatePath(source, width), truncatePath(destination, width)}
+			if showResults {
+				row = append(row, truncateText(symlinkStatus(symlink), width))
 			}
-
-			if len(destination) > 45 {
-				extension := path.Ext(destination)
-				destination = destination[:40] + "(...)" + extension
-			}
-
-			table.Row(source, destination)
+			table.Row(row...)
 		}
 
 		fmt.Println(table)
+	case JSONFormat:
+		printSymlinksAsJSON(symlinks)
+	case NDJSONFormat:
+		printSymlinksAsNDJSON(symlinks)
+	case CSVFormat:
+		printSymlinksAsCSV(symlinks, ',')
+	case TSVFormat:
+		printSymlinksAsCSV(symlinks, '\t')
+	case LinesFormat:
+		printSymlinksAsLines(symlinks, settings)
+	case DiffFormat:
+		printSymlinksAsDiff(symlinks, settings)
 	}
 }
 
@@ -350,7 +606,9 @@ func findRootDirectoryOfAllPaths(paths []string) string {
 	rootDir := filepath.Dir(paths[0])
 
 	for _, path := range paths[1:] {
-		for !strings.HasPrefix(path, rootDir) {
+		// "." and "/" are their own parent, so paths with nothing in
+		// common stop there.
+		for rootDir != path && !link.IsWithin(rootDir, path) && filepath.Dir(rootDir) != rootDir {
 			rootDir = filepath.Dir(rootDir)
 		}
 	}
@@ -360,6 +618,7 @@ func findRootDirectoryOfAllPaths(paths []string) string {
 
 type node struct {
 	value    string
+	marker   string
 	children []*node
 }
 
@@ -401,24 +660,73 @@ func (n *node) getChild(value string) *node {
 	return nil
 }
 
-func (n *node) toLipglossTree() tree.Node {
-	if len(n.value) >= 45 {
-		extension := path.Ext(n.value)
-		n.value = n.value[:40] + "(...)" + extension
+// toLipglossTree renders the node and its children, truncating every value
+// so the node fits in width cells once indented. A width of zero disables
+// truncation.
+func (n *node) toLipglossTree(width int) tree.Node {
+	value := n.value
+	if n.marker != "" {
+		value = n.marker + " " + truncatePath(value, max(width-lipgloss.Width(n.marker)-1, 1))
+	} else {
+		value = truncatePath(value, width)
+	}
+
+	childWidth := 0
+	if width > 0 {
+		childWidth = max(width-treeIndentWidth, 1)
 	}
-	tree := tree.Root(n.value)
+
+	tree := tree.Root(value)
 	for _, child := range n.children {
-		tree.Child(child.toLipglossTree())
+		tree.Child(child.toLipglossTree(childWidth))
 	}
 	return tree
 }
 
 func main() {
-	flags := rootCmd.Flags()
-	flags.BoolP(VerboseFlag, VerboseFlagShort, false, "Enable verbose output (good for debugging)")
-	flags.BoolP(ConfirmFlag, ConfirmFlagShort, false, "Asks for user confirmation before creating symlinks")
+	flags := rootCmd.PersistentFlags()
+	flags.CountP(VerboseFlag, VerboseFlagShort, "Log more details to stderr, repeat (-vv) to log template filling and matching")
+	flags.BoolP(QuietFlag, QuietFlagShort, false, "Only log errors")
+	flags.String(LogFormatFlag, TextLogFormat, "Log format: text or json")
+	flags.String(LogFileFlag, "", "Append logs to this file instead of stderr")
+	flags.StringP(ConfirmFlag, ConfirmFlagShort, "", "Asks for user confirmation before creating symlinks, once for all of them or for each one (--confirm=each)")
+	flags.Lookup(ConfirmFlag).NoOptDefVal = ConfirmAll
+	flags.BoolP(ReviewFlag, ReviewFlagShort, false, "Review, toggle and edit the planned symlinks in an interactive screen before creating them")
 	flags.BoolP(DryRunFlag, DryRunFlagShort, false, "Perform a trial run with no changes made")
+	flags.Bool(ReplaceFlag, false, "Replace existing symlinks that point somewhere else")
+	flags.Bool(NoTruncateFlag, false, "Never shorten paths to fit the terminal width")
+	flags.String(ColorFlag, ColorAuto, "When to use colors: auto, always or never (auto respects NO_COLOR and disables colors when piped)")
+	flags.String(ThemeFlag, "", "Path of a JSON theme file (defaults to supalink/theme.json in the user config directory)")
 	flags.StringArrayP(StepFlag, StepFlagShort, make([]string, 0), "Step number to break destination path into subdirectories")
-	flags.StringP(FormatFlag, FormatFlagShort, TreeFormat, "Format of the destination path")
-	rootCmd.Execute()
+	flags.StringP(FormatFlag, FormatFlagShort, TreeFormat, "Output format: "+strings.Join(formats, ", "))
+	flags.String(RegexEngineFlag, link.RE2Engine, "Regex engine for the source pattern: re2 (linear time) or pcre (backtracking, with lookarounds, backreferences and atomic groups)")
+	flags.Duration(RegexTimeoutFlag, link.DefaultMatchTimeout, "How long the pcre engine may spend matching a single path before skipping it")
+	flags.StringArray(DestRootFlag, make([]string, 0), "Directory destinations have to stay within, repeat it to allow several (defaults to the fixed directory the template starts with)")
+	flags.Bool(AllowSeparatorsFlag, false, "Let captured values contain path separators, . or .., to mirror subdirectories of the source")
+	flags.Bool(Print0Flag, false, "Print source and destination pairs separated by NUL characters (implies --format lines)")
+	for _, cmd := range []*cobra.Command{rootCmd, mapCmd, applyCmd} {
+		cmd.Flags().Bool(StagedFlag, false, "Build the whole destination tree in a staging directory next to it and swap it into place once complete, keeping the previous tree as a backup")
+		cmd.Flags().Bool(AtomicFlag, false, "Stop at the first symlink that fails and roll back every change made by the run")
+	}
+	for _, cmd := range []*cobra.Command{rootCmd, mapCmd, planCmd} {
+		cmd.Flags().BoolP(IncrementalFlag, IncrementalFlagShort, false, "Skip sources linked by a previous run and continue its step counters")
+		cmd.Flags().StringP(ManifestFlag, ManifestFlagShort, "", "Path of the manifest recording linked paths (defaults to .supalink.json in the destination root)")
+	}
+	for _, cmd := range []*cobra.Command{rootCmd, planCmd} {
+		cmd.Flags().BoolP(FromStdinFlag, FromStdinFlagShort, false, "Read NUL-separated source paths from stdin instead of walking the root directory")
+	}
+	rootCmd.Flags().StringArray(ExampleFlag, make([]string, 0), "Learn the regex and template from an example, written as SOURCE=>DESTINATION, instead of taking them as arguments (repeat it for more examples)")
+	rootCmd.Flags().String(FromSnapshotFlag, "", "Run against a tree recorded by the snapshot command instead of the real filesystem, without changing anything on disk")
+	planCmd.Flags().StringP(OutputFlag, OutputFlagShort, defaultPlanPath, "Path of the plan file to write")
+	watchCmd.Flags().Duration(SettleFlag, 5*time.Second, "How long a new file must stop growing before it is linked")
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(mapCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(explainCmd)
+	rootCmd.AddCommand(suggestCmd)
+	os.Exit(execute())
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"supalink/link"
+)
+
+const manifestFileName = ".supalink.json"
+
+// manifest records the symlinks that exist after a run, and the sources the
+// user declined, so an incremental run can tell which sources are already done
+// and where the step counters stopped.
+type manifest struct {
+	Source      string         `json:"source"`
+	Destination string         `json:"destination,omitempty"`
+	Links       []manifestLink `json:"links"`
+	Declined    []string       `json:"declined,omitempty"`
+}
+
+type manifestLink struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Step        int    `json:"step,omitempty"`
+	StepCount   int    `json:"step_count,omitempty"`
+}
+
+func newManifest(srcPath, destPath string) *manifest {
+	return &manifest{Source: srcPath, Destination: destPath, Links: make([]manifestLink, 0)}
+}
+
+// defaultManifestPath places the manifest in the deepest directory of the
+// destination template that doesn't depend on any parameter.
+func defaultManifestPath(destPath string) string {
+	return filepath.Join(link.NewTemplate(destPath).Root(), manifestFileName)
+}
+
+// loadManifest resolves where the manifest lives and, for incremental runs,
+// reads what the previous run recorded there.
+func loadManifest(settings settings, defaultPath, srcPath, destPath string) (string, *manifest, error) {
+	manifestPath := settings.Manifest
+	if manifestPath == "" {
+		manifestPath = defaultPath
+	}
+
+	if !settings.Incremental {
+		return manifestPath, newManifest(srcPath, destPath), nil
+	}
+
+	previousManifest, err := readManifest(manifestPath, srcPath, destPath)
+	if err != nil {
+		return manifestPath, nil, err
+	}
+	slog.Info("Loaded previously linked paths from manifest", "count", len(previousManifest.Links), "manifest", manifestPath)
+
+	return manifestPath, previousManifest, nil
+}
+
+func readManifest(path, srcPath, destPath string) (*manifest, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newManifest(srcPath, destPath), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+
+	m := newManifest(srcPath, destPath)
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	if m.Source != srcPath || m.Destination != destPath {
+		return nil, fmt.Errorf("manifest %s was written for a different command (%s -> %s)", path, m.Source, m.Destination)
+	}
+
+	return m, nil
+}
+
+func writeManifest(path string, m *manifest) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (m *manifest) sources() map[string]bool {
+	sources := make(map[string]bool, len(m.Links))
+	for _, linked := range m.Links {
+		sources[linked.Source] = true
+	}
+	for _, source := range m.Declined {
+		sources[source] = true
+	}
+	return sources
+}
+
+// stepCounter returns a step counter positioned on the last recorded step, so
+// the next symlink continues numbering where the previous run stopped.
+func (m *manifest) stepCounter(steps []int) *link.StepCounter {
+	step, stepCount := 0, 0
+	for _, linked := range m.Links {
+		if linked.Step > step || (linked.Step == step && linked.StepCount > stepCount) {
+			step = linked.Step
+			stepCount = linked.StepCount
+		}
+	}
+
+	counter := link.NewStepCounter(steps)
+	counter.Resume(step, stepCount)
+	return counter
+}
+
+// with returns a copy of the manifest that also records every symlink that
+// now points at its source, and every symlink the user declined.
+func (m *manifest) with(symlinks link.Plan) *manifest {
+	updated := newManifest(m.Source, m.Destination)
+	updated.Links = append(updated.Links, m.Links...)
+	updated.Declined = append(updated.Declined, m.Declined...)
+
+	for _, symlink := range symlinks {
+		if symlink.Action == link.ExcludeAction {
+			updated.Declined = append(updated.Declined, symlink.Source)
+			continue
+		}
+		if !link.IsLinkedTo(link.OS, symlink.Destination, symlink.Source) {
+			continue
+		}
+		updated.Links = append(updated.Links, manifestLink{
+			Source:      symlink.Source,
+			Destination: symlink.Destination,
+			Step:        symlink.Step,
+			StepCount:   symlink.StepCount,
+		})
+	}
+
+	return updated
+}
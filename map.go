@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"supalink/link"
+
+	"github.com/spf13/cobra"
+)
+
+var mapCmd = &cobra.Command{
+	Use:   "map <mapping file>",
+	Short: "Create symlinks from explicit source,destination rows of a CSV (or TSV) file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mappingPath := args[0]
+
+		settings, err := getSettings(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		symlinks, err := readMappingFile(mappingPath)
+		if err != nil {
+			return err
+		}
+		slog.Info("Read rows from mapping file", "count", len(symlinks), "file", mappingPath)
+
+		destinations := make([]string, 0, len(symlinks))
+		for _, symlink := range symlinks {
+			destinations = append(destinations, symlink.Destination)
+		}
+		root := findRootDirectoryOfAllPaths(destinations)
+		defaultPath := filepath.Join(root, manifestFileName)
+
+		manifestPath, previousManifest, err := loadManifest(settings, defaultPath, mappingPath, "")
+		if err != nil {
+			return err
+		}
+
+		linkedSources := previousManifest.sources()
+		pending := make(link.Plan, 0, len(symlinks))
+		for _, symlink := range symlinks {
+			if linkedSources[symlink.Source] {
+				slog.Debug("Path already linked by a previous run", "path", symlink.Source)
+				continue
+			}
+			symlink.Action = link.Action(link.OS, symlink.Source, symlink.Destination, settings.Replace)
+			pending = append(pending, symlink)
+		}
+
+		// Rows are only confined to the directories given with --dest-root,
+		// the root computed from them would hold them all anyway.
+		return linkAndRecord(cmd.Context(), pending, settings, root, manifestPath, previousManifest)
+	},
+}
+
+// readMappingFile reads source,destination rows. Files ending in .tsv are
+// tab-separated, and a leading "source,destination" header row is skipped.
+func readMappingFile(mappingPath string) (link.Plan, error) {
+	file, err := os.Open(mappingPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mapping file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	if strings.EqualFold(filepath.Ext(mappingPath), ".tsv") {
+		reader.Comma = '\t'
+	}
+
+	symlinks := make(link.Plan, 0)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping file %s: %w", mappingPath, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if line == 1 && strings.EqualFold(row[0], "source") && strings.EqualFold(row[1], "destination") {
+			continue
+		}
+
+		if row[0] == "" || row[1] == "" {
+			return nil, fmt.Errorf("invalid mapping file %s: line %d has an empty source or destination", mappingPath, line)
+		}
+
+		// Relative sources would point somewhere else from the destination,
+		// so both are resolved against the working directory.
+		source, err := filepath.Abs(row[0])
+		if err != nil {
+			return nil, err
+		}
+		destination, err := filepath.Abs(row[1])
+		if err != nil {
+			return nil, err
+		}
+		symlinks = append(symlinks, link.PlanItem{Source: source, Destination: destination})
+	}
+
+	return symlinks, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+
+	"supalink/link"
+)
+
+// symlinkRecord is the shape of a symlink in the machine-readable formats.
+// Field names are part of supalink's output contract, keep them stable.
+type symlinkRecord struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Captures    []string `json:"captures"`
+	Step        int      `json:"step"`
+	StepCount   int      `json:"step_count"`
+	Action      string   `json:"action"`
+	Result      string   `json:"result"`
+	Error       string   `json:"error"`
+}
+
+func isMachineReadableFormat(format string) bool {
+	return slices.Contains([]string{JSONFormat, NDJSONFormat, CSVFormat, TSVFormat, LinesFormat}, format)
+}
+
+// printStatus prints messages meant for people. They go to stderr when the
+// output format is machine-readable, so stdout stays parseable.
+func printStatus(settings settings, message string, args ...any) {
+	if isMachineReadableFormat(settings.Format) {
+		fmt.Fprintf(os.Stderr, message, args...)
+		return
+	}
+	fmt.Printf(message, args...)
+}
+
+func toSymlinkRecord(symlink link.PlanItem) symlinkRecord {
+	captures := symlink.Captures
+	if captures == nil {
+		captures = make([]string, 0)
+	}
+
+	return symlinkRecord{
+		Source:      symlink.Source,
+		Destination: symlink.Destination,
+		Captures:    captures,
+		Step:        symlink.Step,
+		StepCount:   symlink.StepCount,
+		Action:      symlink.Action,
+		Result:      symlink.Result,
+		Error:       symlink.Error,
+	}
+}
+
+func printSymlinksAsJSON(symlinks link.Plan) {
+	records := make([]symlinkRecord, 0, len(symlinks))
+	for _, symlink := range symlinks {
+		records = append(records, toSymlinkRecord(symlink))
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(records)
+}
+
+func printSymlinksAsNDJSON(symlinks link.Plan) {
+	encoder := json.NewEncoder(os.Stdout)
+	for _, symlink := range symlinks {
+		encoder.Encode(toSymlinkRecord(symlink))
+	}
+}
+
+// printSymlinksAsCSV writes one row per symlink, with a capture_N column for
+// every capture group of the source pattern.
+func printSymlinksAsCSV(symlinks link.Plan, separator rune) {
+	captureCount := 0
+	for _, symlink := range symlinks {
+		captureCount = max(captureCount, len(symlink.Captures))
+	}
+
+	header := []string{"source", "destination"}
+	for i := 1; i <= captureCount; i++ {
+		header = append(header, "capture_"+strconv.Itoa(i))
+	}
+	header = append(header, "step", "step_count", "action", "result", "error")
+
+	writer := csv.NewWriter(os.Stdout)
+	writer.Comma = separator
+	writer.Write(header)
+
+	for _, symlink := range symlinks {
+		row := []string{symlink.Source, symlink.Destination}
+		for i := 0; i < captureCount; i++ {
+			capture := ""
+			if i < len(symlink.Captures) {
+				capture = symlink.Captures[i]
+			}
+			row = append(row, capture)
+		}
+		row = append(row,
+			strconv.Itoa(symlink.Step),
+			strconv.Itoa(symlink.StepCount),
+			symlink.Action,
+			symlink.Result,
+			symlink.Error,
+		)
+		writer.Write(row)
+	}
+
+	writer.Flush()
+}
+
+// printSymlinksAsLines writes the source and destination of every symlink as
+// two consecutive entries, so the output can be consumed with `xargs -n2`.
+func printSymlinksAsLines(symlinks link.Plan, settings settings) {
+	terminator := "\n"
+	if settings.Print0 {
+		terminator = "\x00"
+	}
+
+	writer := bufio.NewWriter(os.Stdout)
+	for _, symlink := range symlinks {
+		writer.WriteString(symlink.Source + terminator)
+		writer.WriteString(symlink.Destination + terminator)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"supalink/link"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	OutputFlag      = "output"
+	OutputFlagShort = "o"
+)
+
+const defaultPlanPath = "plan.json"
+
+// plan is a saved plan: everything matching and templating decided, written
+// by `supalink plan` so it can be reviewed (or edited) before `supalink apply`
+// executes it. Setting the action of an item to "exclude" skips it.
+type plan struct {
+	Source      string     `json:"source"`
+	Destination string     `json:"destination"`
+	Manifest    string     `json:"manifest"`
+	Incremental bool       `json:"incremental,omitempty"`
+	Items       []planItem `json:"items"`
+}
+
+// planItem is a planned symlink, along with what its source looked like when
+// it was planned, so apply can tell whether it changed since.
+type planItem struct {
+	Source         string    `json:"source"`
+	Destination    string    `json:"destination"`
+	Captures       []string  `json:"captures"`
+	Step           int       `json:"step,omitempty"`
+	StepCount      int       `json:"step_count,omitempty"`
+	Action         string    `json:"action"`
+	Error          string    `json:"error,omitempty"`
+	SourceSize     int64     `json:"source_size"`
+	SourceModified time.Time `json:"source_modified"`
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan <source path regex> <destination path template>",
+	Short: "Match and template paths, and save the resulting plan to a file for `supalink apply`",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := getSettings(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		planner, err := newPlanner(settings, args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		symlinks, manifestPath, _, err := matchPaths(cmd.Context(), planner, settings)
+		if err != nil {
+			return err
+		}
+		if len(symlinks) == 0 {
+			printStatus(settings, "No matching paths found.\n")
+			if isMachineReadableFormat(settings.Format) {
+				printSymlinks(symlinks, settings)
+			}
+			return exitWith(ExitNoMatches, "no matching paths found")
+		}
+
+		symlinks.MarkCollisions()
+
+		p, err := newPlan(symlinks, planner.Pattern.String(), planner.Template.String(), manifestPath, settings)
+		if err != nil {
+			return err
+		}
+
+		planPath := cmd.Flags().Lookup(OutputFlag).Value.String()
+		if err := writePlan(planPath, p); err != nil {
+			return err
+		}
+
+		printSymlinks(symlinks, settings)
+
+		conflicts := 0
+		for _, symlink := range symlinks {
+			if symlink.Action == link.ConflictAction || symlink.Action == link.RefuseAction {
+				conflicts++
+			}
+		}
+		printStatus(settings, "Plan with %d symlinks (%d conflicts) written to %s\n", len(symlinks), conflicts, planPath)
+
+		return nil
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan file>",
+	Short: "Create the symlinks of a plan written by `supalink plan`",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		planPath := args[0]
+
+		settings, err := getSettings(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		p, err := readPlan(planPath)
+		if err != nil {
+			return err
+		}
+		if settings.Staged && p.Incremental {
+			return fmt.Errorf("plan %s was made with --%s and cannot be applied with --%s", planPath, IncrementalFlag, StagedFlag)
+		}
+
+		if problems := p.check(); len(problems) > 0 {
+			return fmt.Errorf("plan %s is out of date, plan again:\n  %s", planPath, strings.Join(problems, "\n  "))
+		}
+
+		previousManifest := newManifest(p.Source, p.Destination)
+		if p.Incremental {
+			previousManifest, err = readManifest(p.Manifest, p.Source, p.Destination)
+			if err != nil {
+				return err
+			}
+		}
+
+		symlinks := p.symlinks()
+
+		root := link.NewTemplate(p.Destination).Root()
+		settings.DestRoots = settings.roots(root)
+		return linkAndRecord(cmd.Context(), symlinks, settings, root, p.Manifest, previousManifest)
+	},
+}
+
+func newPlan(symlinks link.Plan, srcPath, destPath, manifestPath string, settings settings) (*plan, error) {
+	p := &plan{
+		Source:      srcPath,
+		Destination: destPath,
+		Manifest:    manifestPath,
+		Incremental: settings.Incremental,
+		Items:       make([]planItem, 0, len(symlinks)),
+	}
+
+	for _, symlink := range symlinks {
+		info, err := os.Stat(symlink.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source %s: %w", symlink.Source, err)
+		}
+
+		captures := symlink.Captures
+		if captures == nil {
+			captures = make([]string, 0)
+		}
+
+		p.Items = append(p.Items, planItem{
+			Source:         symlink.Source,
+			Destination:    symlink.Destination,
+			Captures:       captures,
+			Step:           symlink.Step,
+			StepCount:      symlink.StepCount,
+			Action:         symlink.Action,
+			Error:          symlink.Error,
+			SourceSize:     info.Size(),
+			SourceModified: info.ModTime(),
+		})
+	}
+
+	return p, nil
+}
+
+func writePlan(path string, p *plan) error {
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write plan %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func readPlan(path string) (*plan, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan %s: %w", path, err)
+	}
+
+	p := &plan{}
+	if err := json.Unmarshal(content, p); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+
+	for _, item := range p.Items {
+		switch item.Action {
+		case link.CreateAction, link.SkipAction, link.ReplaceAction, link.ConflictAction, link.ExcludeAction, link.RefuseAction:
+		default:
+			return nil, fmt.Errorf("invalid plan %s: unknown action %q for %s", path, item.Action, item.Source)
+		}
+	}
+
+	return p, nil
+}
+
+// check compares the plan with the filesystem, and describes every source
+// that changed and every destination that is no longer as it was planned.
+func (p *plan) check() []string {
+	problems := make([]string, 0)
+	for _, item := range p.Items {
+		if item.Action == link.ExcludeAction || item.Action == link.RefuseAction {
+			continue
+		}
+
+		info, err := os.Stat(item.Source)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("source %s is gone: %v", item.Source, err))
+			continue
+		}
+		if info.Size() != item.SourceSize || !info.ModTime().Equal(item.SourceModified) {
+			problems = append(problems, fmt.Sprintf("source %s changed since planning", item.Source))
+			continue
+		}
+
+		// Conflicts are planned again by symlinks, whatever their cause.
+		if item.Action == link.ConflictAction {
+			continue
+		}
+
+		action := link.Action(link.OS, item.Source, item.Destination, item.Action == link.ReplaceAction)
+		switch {
+		case action == item.Action:
+		case item.Action == link.CreateAction && action != link.SkipAction:
+			problems = append(problems, fmt.Sprintf("destination %s appeared since planning", item.Destination))
+		case item.Action != link.CreateAction:
+			problems = append(problems, fmt.Sprintf("destination %s changed since planning (planned to %s, would now %s)", item.Destination, item.Action, action))
+		}
+	}
+	return problems
+}
+
+// symlinks turns the plan back into symlinks to create. Conflicts and
+// destinations that may have been linked since are planned again, collisions
+// are checked again by linkAndRecord since destinations may have been edited.
+func (p *plan) symlinks() link.Plan {
+	symlinks := make(link.Plan, 0, len(p.Items))
+	for _, item := range p.Items {
+		action := item.Action
+		if action == link.CreateAction || action == link.ConflictAction {
+			action = link.Action(link.OS, item.Source, item.Destination, false)
+		}
+
+		symlinks = append(symlinks, link.PlanItem{
+			Source:      item.Source,
+			Destination: item.Destination,
+			Captures:    item.Captures,
+			Step:        item.Step,
+			StepCount:   item.StepCount,
+			Action:      action,
+		})
+	}
+
+	return symlinks
+}
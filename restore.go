@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+
+	"supalink/link"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <destination path template>",
+	Short: "Swap the destination tree replaced by the last --staged run back into place",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root := link.NewTemplate(args[0]).Root()
+		applier := &link.Applier{}
+		if err := applier.Restore(root); err != nil {
+			return err
+		}
+
+		fmt.Printf("Restored %s from %s\n", root, root+link.BackupSuffix)
+		return nil
+	},
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"supalink/link"
+)
+
+// resultOrder is the order in which results are counted in the summary.
+var resultOrder = []string{link.CreatedResult, link.ReplacedResult, link.SkippedResult, link.FailedResult, link.RolledBackResult, link.CancelledResult}
+
+func hasResults(symlinks link.Plan) bool {
+	for _, symlink := range symlinks {
+		if symlink.Result != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// symlinkStatus describes the result of a symlink, with the reason it failed.
+func symlinkStatus(symlink link.PlanItem) string {
+	if symlink.Result == link.FailedResult && symlink.Error != "" {
+		return symlink.Result + ": " + symlink.Error
+	}
+	return symlink.Result
+}
+
+// resultMarkers maps every destination to its styled result, for marking the
+// destination tree.
+func resultMarkers(symlinks link.Plan) map[string]string {
+	markers := make(map[string]string, len(symlinks))
+	for _, symlink := range symlinks {
+		if symlink.Result != "" {
+			markers[symlink.Destination] = resultStyle(symlink.Result).Render("[" + symlink.Result + "]")
+		}
+	}
+	return markers
+}
+
+// printSummary prints how many symlinks ended up with each result, and how
+// long creating them took.
+func printSummary(symlinks link.Plan, settings settings, elapsed time.Duration) {
+	counts := make(map[string]int)
+	for _, symlink := range symlinks {
+		counts[symlink.Result]++
+	}
+
+	parts := make([]string, 0, len(resultOrder))
+	for _, result := range resultOrder {
+		if counts[result] > 0 || result == link.CreatedResult || result == link.FailedResult {
+			parts = append(parts, resultStyle(result).Render(fmt.Sprintf("%d %s", counts[result], result)))
+		}
+	}
+
+	if elapsed >= time.Millisecond {
+		elapsed = elapsed.Round(time.Millisecond)
+	} else {
+		elapsed = elapsed.Round(time.Microsecond)
+	}
+
+	printStatus(settings, "%s in %s\n", strings.Join(parts, ", "), elapsed)
+}
+
+// logResults logs the result of every symlink once they are applied.
+func logResults(symlinks link.Plan) {
+	for _, symlink := range symlinks {
+		switch symlink.Result {
+		case link.CreatedResult:
+			slog.Info("Symlink created", "source", symlink.Source, "destination", symlink.Destination)
+		case link.ReplacedResult:
+			slog.Info("Symlink replaced", "source", symlink.Source, "destination", symlink.Destination)
+		case link.SkippedResult:
+			slog.Info("Symlink skipped", "source", symlink.Source, "destination", symlink.Destination, "action", symlink.Action)
+		case link.RolledBackResult:
+			slog.Info("Symlink rolled back", "source", symlink.Source, "destination", symlink.Destination)
+		case link.FailedResult:
+			slog.Error("Failed to create symlink", "source", symlink.Source, "destination", symlink.Destination, "error", symlink.Error)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"supalink/link"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type reviewMode int
+
+const (
+	browsingMode reviewMode = iota
+	filteringMode
+	editingMode
+)
+
+var reviewCursorStyle = lipgloss.NewStyle().Bold(true).Foreground(whiteColor)
+
+// reviewModel is the Bubble Tea model behind --review. It works on a copy of
+// the planned symlinks and only hands them back once the user accepts.
+type reviewModel struct {
+	settings settings
+	symlinks link.Plan
+	visible  []int
+	cursor   int
+	offset   int
+	height   int
+	width    int
+	mode     reviewMode
+	filter   string
+	input    []rune
+	accepted bool
+	// refused holds why symlinks were refused while planning, so they are
+	// refused again when turned back on without being edited.
+	refused map[int]string
+}
+
+// reviewSymlinks lets the user go through the plan before anything is linked.
+// Items turned off are excluded, edited destinations are planned again, and
+// the function reports whether the user accepted the result.
+func reviewSymlinks(symlinks link.Plan, settings settings) (bool, error) {
+	model := newReviewModel(symlinks, settings)
+
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if settings.FromStdin {
+		options = append(options, tea.WithInputTTY())
+	}
+	if isMachineReadableFormat(settings.Format) {
+		options = append(options, tea.WithOutput(os.Stderr))
+	}
+
+	finalModel, err := tea.NewProgram(model, options...).Run()
+	if err != nil {
+		return false, fmt.Errorf("failed to run review screen: %w", err)
+	}
+
+	reviewed := finalModel.(*reviewModel)
+	if !reviewed.accepted {
+		return false, nil
+	}
+
+	copy(symlinks, reviewed.symlinks)
+
+	return true, nil
+}
+
+func newReviewModel(symlinks link.Plan, settings settings) *reviewModel {
+	model := &reviewModel{
+		settings: settings,
+		symlinks: slices.Clone(symlinks),
+		height:   20,
+		refused:  make(map[int]string),
+	}
+	for i, symlink := range symlinks {
+		if symlink.Action == link.RefuseAction {
+			model.refused[i] = symlink.Error
+		}
+	}
+	model.applyFilter()
+	return model
+}
+
+func (m *reviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		if msg.Height > 0 {
+			m.height = max(msg.Height-6, 1)
+		}
+		m.scroll()
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+
+		switch m.mode {
+		case filteringMode:
+			m.updateFilter(msg)
+		case editingMode:
+			m.updateEdit(msg)
+		default:
+			return m, m.updateBrowse(msg)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *reviewModel) updateBrowse(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q", "esc":
+		return tea.Quit
+	case "enter":
+		m.accepted = true
+		return tea.Quit
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.visible)-1, 0))
+	case "pgup":
+		m.cursor = max(m.cursor-m.height, 0)
+	case "pgdown":
+		m.cursor = min(m.cursor+m.height, max(len(m.visible)-1, 0))
+	case " ", "x":
+		if index, ok := m.current(); ok {
+			m.setIncluded(index, m.symlinks[index].Action == link.ExcludeAction)
+			replanSymlinks(m.symlinks, m.settings)
+		}
+	case "a":
+		for _, index := range m.visible {
+			m.setIncluded(index, true)
+		}
+		replanSymlinks(m.symlinks, m.settings)
+	case "n":
+		for _, index := range m.visible {
+			m.setIncluded(index, false)
+		}
+		replanSymlinks(m.symlinks, m.settings)
+	case "e":
+		if index, ok := m.current(); ok {
+			m.mode = editingMode
+			m.input = []rune(m.symlinks[index].Destination)
+		}
+	case "/":
+		m.mode = filteringMode
+		m.input = []rune(m.filter)
+	}
+
+	m.scroll()
+	return nil
+}
+
+func (m *reviewModel) updateFilter(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "enter":
+		m.mode = browsingMode
+	case "esc":
+		m.mode = browsingMode
+		m.input = nil
+	default:
+		m.updateInput(msg)
+	}
+
+	m.filter = string(m.input)
+	m.applyFilter()
+}
+
+func (m *reviewModel) updateEdit(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "enter":
+		if index, ok := m.current(); ok && len(m.input) > 0 {
+			m.symlinks[index].Destination = string(m.input)
+			delete(m.refused, index)
+			if m.symlinks[index].Action == link.RefuseAction {
+				m.symlinks[index].Action = link.CreateAction
+			}
+			replanSymlinks(m.symlinks, m.settings)
+		}
+		m.mode = browsingMode
+	case "esc":
+		m.mode = browsingMode
+	default:
+		m.updateInput(msg)
+	}
+}
+
+func (m *reviewModel) updateInput(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyBackspace:
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+	case tea.KeyCtrlU:
+		m.input = nil
+	case tea.KeyRunes, tea.KeySpace:
+		m.input = append(m.input, msg.Runes...)
+	}
+}
+
+func (m *reviewModel) current() (int, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.visible) {
+		return 0, false
+	}
+	return m.visible[m.cursor], true
+}
+
+func (m *reviewModel) applyFilter() {
+	filter := strings.ToLower(m.filter)
+	m.visible = m.visible[:0]
+	for i, symlink := range m.symlinks {
+		if filter == "" ||
+			strings.Contains(strings.ToLower(symlink.Source), filter) ||
+			strings.Contains(strings.ToLower(symlink.Destination), filter) {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.cursor = min(m.cursor, max(len(m.visible)-1, 0))
+	m.scroll()
+}
+
+// setIncluded turns a symlink on or off. Symlinks turned back on are given a
+// placeholder action until they are planned again, unless they were refused.
+func (m *reviewModel) setIncluded(index int, included bool) {
+	switch {
+	case !included:
+		m.symlinks[index].Action = link.ExcludeAction
+		m.symlinks[index].Error = ""
+	case m.symlinks[index].Action != link.ExcludeAction:
+	case m.refused[index] != "":
+		m.symlinks[index].Action = link.RefuseAction
+		m.symlinks[index].Error = m.refused[index]
+	default:
+		m.symlinks[index].Action = link.CreateAction
+	}
+}
+
+func (m *reviewModel) scroll() {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+}
+
+func (m *reviewModel) View() string {
+	var view strings.Builder
+
+	selected, conflicts := 0, 0
+	for _, symlink := range m.symlinks {
+		if symlink.Action == link.ExcludeAction {
+			continue
+		}
+		selected++
+		if symlink.Action == link.ConflictAction || symlink.Action == link.RefuseAction {
+			conflicts++
+		}
+	}
+	view.WriteString(styles.Header.Render(fmt.Sprintf("Review symlinks: %d of %d selected, %d conflicts", selected, len(m.symlinks), conflicts)))
+	view.WriteString("\n\n")
+
+	end := min(m.offset+m.height, len(m.visible))
+	for row := m.offset; row < end; row++ {
+		index := m.visible[row]
+		symlink := m.symlinks[index]
+
+		excluded := symlink.Action == link.ExcludeAction
+		checkbox := "[x]"
+		if excluded {
+			checkbox = "[ ]"
+		}
+		marker := " "
+		if symlink.Action == link.ConflictAction || symlink.Action == link.RefuseAction {
+			marker = "!"
+		}
+		line := fmt.Sprintf("%s %s %s -> %s", checkbox, marker, symlink.Source, symlink.Destination)
+		if symlink.Error != "" {
+			line += " (" + symlink.Error + ")"
+		}
+
+		style := lipgloss.NewStyle()
+		switch {
+		case excluded:
+			style = styles.Skipped.Strikethrough(true)
+		case symlink.Action == link.ConflictAction, symlink.Action == link.RefuseAction:
+			style = styles.Conflict
+		}
+		if row == m.cursor {
+			style = style.Inherit(reviewCursorStyle)
+			line = "> " + line
+		} else {
+			line = "  " + line
+		}
+		if m.width > 0 {
+			style = style.MaxWidth(m.width)
+		}
+
+		view.WriteString(style.Render(line))
+		view.WriteString("\n")
+	}
+	if len(m.visible) == 0 {
+		view.WriteString(styles.Unchanged.Render("  No symlinks match the filter."))
+		view.WriteString("\n")
+	}
+
+	view.WriteString("\n")
+	switch m.mode {
+	case filteringMode:
+		view.WriteString("Filter: " + string(m.input) + "█")
+	case editingMode:
+		view.WriteString("Destination: " + string(m.input) + "█")
+	default:
+		help := "space toggle • a/n select all/none • e edit destination • / filter • enter apply • q cancel"
+		if m.filter != "" {
+			help = "filter: " + m.filter + " • " + help
+		}
+		view.WriteString(styles.Unchanged.Render(help))
+	}
+
+	return view.String()
+}
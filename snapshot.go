@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"supalink/link"
+
+	"github.com/spf13/cobra"
+)
+
+const FromSnapshotFlag = "from-snapshot"
+
+const (
+	DirectorySnapshotType = "directory"
+	FileSnapshotType      = "file"
+	SymlinkSnapshotType   = "symlink"
+	OtherSnapshotType     = "other"
+)
+
+// snapshot records what a tree looks like, without its content, so a run can
+// be reproduced against it elsewhere with --from-snapshot.
+type snapshot struct {
+	Roots   []string        `json:"roots"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Path     string    `json:"path"`
+	Type     string    `json:"type"`
+	Size     int64     `json:"size,omitempty"`
+	Modified time.Time `json:"modified"`
+	Target   string    `json:"target,omitempty"`
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot <directory>...",
+	Short: "Print the names, types, sizes, modification times and symlink targets of a tree as JSON, for --from-snapshot",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := takeSnapshot(args)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	},
+}
+
+// takeSnapshot walks every root. Paths are recorded as walked, so they match
+// a source pattern written the same way as the roots.
+func takeSnapshot(roots []string) (*snapshot, error) {
+	s := &snapshot{Roots: roots, Entries: make([]snapshotEntry, 0)}
+
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			snapshotEntry := snapshotEntry{Path: path, Modified: info.ModTime()}
+			switch {
+			case info.IsDir():
+				snapshotEntry.Type = DirectorySnapshotType
+			case info.Mode()&fs.ModeSymlink != 0:
+				snapshotEntry.Type = SymlinkSnapshotType
+				snapshotEntry.Target, err = os.Readlink(path)
+				if err != nil {
+					return err
+				}
+			case info.Mode().IsRegular():
+				snapshotEntry.Type = FileSnapshotType
+				snapshotEntry.Size = info.Size()
+			default:
+				snapshotEntry.Type = OtherSnapshotType
+			}
+			s.Entries = append(s.Entries, snapshotEntry)
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", root, err)
+		}
+	}
+
+	return s, nil
+}
+
+// loadSnapshot rebuilds the tree recorded in a snapshot file in memory. Files
+// have their recorded size but read as zeros, and anything that is neither a
+// directory nor a symlink becomes an empty file.
+func loadSnapshot(path string) (*link.MemoryFS, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+
+	memory := link.NewMemoryFS()
+	for _, entry := range s.Entries {
+		err := memory.MkdirAll(filepath.Dir(entry.Path))
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot %s: %s: %w", path, entry.Path, err)
+		}
+
+		switch entry.Type {
+		case DirectorySnapshotType:
+			err = memory.MkdirAll(entry.Path)
+		case SymlinkSnapshotType:
+			err = memory.Symlink(entry.Target, entry.Path)
+		case FileSnapshotType, OtherSnapshotType:
+			err = memory.WriteFile(entry.Path, nil)
+			if err == nil {
+				err = memory.Truncate(entry.Path, entry.Size)
+			}
+		default:
+			err = fmt.Errorf("unknown type %q", entry.Type)
+		}
+		// Chtimes would follow symlinks, so they keep the time they were
+		// added at.
+		if err == nil && entry.Type != SymlinkSnapshotType {
+			err = memory.Chtimes(entry.Path, entry.Modified, entry.Modified)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot %s: %s: %w", path, entry.Path, err)
+		}
+	}
+
+	slog.Info("Loaded snapshot", "snapshot", path, "entries", len(s.Entries))
+	return memory, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// readNullSeparatedPaths reads paths terminated by NUL characters, as printed
+// by `find -print0` or `fd -0`. The last path may omit its terminator.
+func readNullSeparatedPaths(reader io.Reader) ([]string, error) {
+	paths := make([]string, 0)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+
+	for scanner.Scan() {
+		if path := scanner.Text(); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read paths from stdin: %w", err)
+	}
+
+	return paths, nil
+}
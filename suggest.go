@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	seasonMarkerExp  = regexp.MustCompile(`(?i)(^|[ ._-])(s|season)[ ._-]*$`)
+	episodeMarkerExp = regexp.MustCompile(`(?i)(^|[ ._-])(e|ep|episode)[ ._-]*$`)
+	bracketsExp      = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)|\{[^}]*\}`)
+)
+
+// suggestion is a source regex and destination template inferred from the
+// names of a folder.
+type suggestion struct {
+	Pattern  string
+	Template string
+	// Names is how many of the files follow the naming the suggestion is
+	// based on.
+	Names int
+	Files int
+}
+
+// nameRun is a run of digits, or of anything else, in a file name.
+type nameRun struct {
+	text   string
+	digits bool
+}
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest <directory> [destination directory]",
+	Short: "Propose a regex and a Jellyfin-style template from the file names in a directory, then review the symlinks they give",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := getSettings(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		// Symlinks with relative sources would point somewhere else from
+		// the destination, so both are made absolute.
+		directory, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		destination, err := filepath.Abs(".")
+		if len(args) > 1 {
+			destination, err = filepath.Abs(args[1])
+		}
+		if err != nil {
+			return err
+		}
+
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", directory, err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				names = append(names, entry.Name())
+			}
+		}
+
+		s, err := suggestPattern(directory, names, destination)
+		if err != nil {
+			return err
+		}
+
+		printStatus(settings, "Based on %d of %d files in %s\n", s.Names, s.Files, directory)
+		printStatus(settings, "  Regex     %s\n", s.Pattern)
+		printStatus(settings, "  Template  %s\n", s.Template)
+
+		if !settings.DryRun {
+			// Running out of answers cancels, like answering no.
+			cancelled := false
+			readAnswer := func(prompt string) string {
+				printStatus(settings, "%s", prompt)
+				answer, err := readAnswerLine(os.Stdin)
+				if err != nil {
+					printStatus(settings, "\n")
+					cancelled = true
+				}
+				return answer
+			}
+
+		prompt:
+			for !cancelled {
+				switch strings.ToLower(readAnswer("Use this proposal? [y]es/[n]o/[e]dit: ")) {
+				case "y", "yes":
+					break prompt
+				case "n", "no":
+					cancelled = true
+				case "e", "edit":
+					if pattern := readAnswer("Regex (empty keeps it): "); pattern != "" {
+						s.Pattern = pattern
+					}
+					if cancelled {
+						break prompt
+					}
+					if template := readAnswer("Template (empty keeps it): "); template != "" {
+						s.Template = template
+					}
+					break prompt
+				}
+			}
+			if cancelled {
+				printStatus(settings, "Operation cancelled by user.\n")
+				return exitWith(ExitCancelled, "operation cancelled by user")
+			}
+
+			// Symlinks are always reviewed before they are created, unless
+			// the user asked for a plain confirmation instead.
+			if !settings.Confirm {
+				settings.Review = true
+			}
+		}
+		printStatus(settings, "To run it again: supalink %s %s\n", shellQuote(s.Pattern), shellQuote(s.Template))
+
+		planner, err := newPlanner(settings, s.Pattern, s.Template)
+		if err != nil {
+			return err
+		}
+
+		symlinks, manifestPath, previousManifest, err := matchPaths(cmd.Context(), planner, settings)
+		if err != nil {
+			return err
+		}
+
+		settings.DestRoots = settings.roots(planner.Template.Root())
+		return linkAndRecord(cmd.Context(), symlinks, settings, planner.Template.Root(), manifestPath, previousManifest)
+	},
+}
+
+// suggestPattern compares names and works out which parts are fixed and which
+// vary. Only the names sharing the most common extension and shape are used.
+// Numbers that vary become capture groups, the one after an "S" or "Season"
+// being the season and the next one the episode, and the fixed text before
+// them gives the show name.
+func suggestPattern(directory string, names []string, destination string) (suggestion, error) {
+	s := suggestion{Files: len(names)}
+
+	extensions := make(map[string]int)
+	for _, name := range names {
+		extensions[filepath.Ext(name)]++
+	}
+	extension := ""
+	for candidate, count := range extensions {
+		if count > extensions[extension] || (count == extensions[extension] && candidate < extension) {
+			extension = candidate
+		}
+	}
+
+	shapes := make(map[string][][]nameRun)
+	for _, name := range names {
+		if filepath.Ext(name) != extension {
+			continue
+		}
+		runs := splitRuns(name)
+		shape := ""
+		for _, run := range runs {
+			if run.digits {
+				shape += "0"
+			} else {
+				shape += "a"
+			}
+		}
+		shapes[shape] = append(shapes[shape], runs)
+	}
+	shape := ""
+	for candidate, named := range shapes {
+		if len(named) > len(shapes[shape]) || (len(named) == len(shapes[shape]) && candidate < shape) {
+			shape = candidate
+		}
+	}
+	named := shapes[shape]
+	if len(named) < 2 {
+		return s, fmt.Errorf("need at least two files named alike to compare, found %d", len(named))
+	}
+	s.Names = len(named)
+
+	pattern := regexp.QuoteMeta(filepath.ToSlash(directory)) + "/"
+	prefix, show := "", ""
+	season, episode := "", ""
+	group := 0
+	varies := false
+	for i := range named[0] {
+		values := make([]string, 0, len(named))
+		for _, runs := range named {
+			values = append(values, runs[i].text)
+		}
+		fixed := !slices.ContainsFunc(values, func(value string) bool { return value != values[0] })
+
+		value := values[0]
+		switch {
+		case fixed:
+			pattern += regexp.QuoteMeta(value)
+		case named[0][i].digits:
+			group++
+			value = fmt.Sprintf("$%d", group)
+			pattern += digitsPattern(values)
+		default:
+			pattern += ".+?"
+		}
+
+		// Numbers that never change only count when they are marked as
+		// the season.
+		before := ""
+		if i > 0 {
+			before = named[0][i-1].text
+		}
+		role := false
+		if named[0][i].digits {
+			switch {
+			case season == "" && episode == "" && seasonMarkerExp.MatchString(before):
+				season, role = value, true
+			case fixed:
+			case episode == "":
+				episode, role = value, true
+			case season == "":
+				// Two numbers without markers: the first one was the
+				// season.
+				season, episode, role = episode, value, true
+			}
+		}
+
+		// The show is the fixed text before the first season or episode.
+		if role && !varies && show == "" {
+			show = seasonMarkerExp.ReplaceAllString(prefix, "")
+			show = episodeMarkerExp.ReplaceAllString(show, "")
+		}
+		if !fixed {
+			varies = true
+		}
+		prefix += values[0]
+	}
+
+	if episode == "" {
+		return s, fmt.Errorf("found no number that changes from one file to the next to use as the episode")
+	}
+	if season == "" {
+		season = "01"
+	}
+
+	show = bracketsExp.ReplaceAllString(show, "")
+	show = strings.Map(func(r rune) rune {
+		if r == '.' || r == '_' {
+			return ' '
+		}
+		return r
+	}, show)
+	show = strings.Join(strings.Fields(show), " ")
+	show = strings.TrimFunc(show, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ')'
+	})
+	if show == "" {
+		show = filepath.Base(directory)
+	}
+
+	s.Pattern = pattern
+	s.Template = filepath.ToSlash(filepath.Join(destination, show, "Season "+season, show+" S"+season+"E"+episode+extension))
+	return s, nil
+}
+
+// splitRuns splits name into alternating runs of digits and other text.
+func splitRuns(name string) []nameRun {
+	runs := make([]nameRun, 0)
+	for _, r := range name {
+		digit := isDigit(r)
+		if len(runs) == 0 || runs[len(runs)-1].digits != digit {
+			runs = append(runs, nameRun{digits: digit})
+		}
+		runs[len(runs)-1].text += string(r)
+	}
+	return runs
+}
+
+// isDigit reports whether r is an ASCII digit. Other digits, like full-width
+// ones, are kept as text: [0-9] wouldn't match them and strconv can't read
+// them.
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// digitsPattern captures a number, with a fixed width when every value has
+// the same one.
+func digitsPattern(values []string) string {
+	for _, value := range values {
+		if len(value) != len(values[0]) {
+			return "([0-9]+)"
+		}
+	}
+	return fmt.Sprintf("([0-9]{%d})", len(values[0]))
+}
+
+// readAnswerLine reads a line one byte at a time, so whatever follows it is
+// left for the confirmation or review that comes next. It returns an error,
+// io.EOF at the end of the input, when the input ends or fails before a
+// line could be read.
+func readAnswerLine(reader io.Reader) (string, error) {
+	line := make([]byte, 0)
+	character := make([]byte, 1)
+	for {
+		n, err := reader.Read(character)
+		if n == 1 && character[0] == '\n' {
+			return strings.TrimSpace(string(line)), nil
+		}
+		if n == 1 {
+			line = append(line, character[0])
+		}
+		if err != nil {
+			if len(line) == 0 {
+				return "", err
+			}
+			return strings.TrimSpace(string(line)), nil
+		}
+	}
+}
+
+// shellQuote quotes value for POSIX shells.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"supalink/link"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+// testRecord is the outcome of one sample name in the machine-readable
+// formats of `supalink test`.
+type testRecord struct {
+	Input       string            `json:"input"`
+	Matched     bool              `json:"matched"`
+	Captures    []string          `json:"captures"`
+	Named       map[string]string `json:"named"`
+	Step        int               `json:"step"`
+	StepCount   int               `json:"step_count"`
+	Destination string            `json:"destination"`
+	Error       string            `json:"error"`
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test <source path regex> <destination path template> [-- <name>...]",
+	Short: "Try a regex and template on sample names, read from the arguments or one per line from stdin, without touching the filesystem",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := getSettings(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		planner, err := newPlanner(settings, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		// Destinations are checked against an empty filesystem, so nothing
+		// on disk is looked at.
+		planner.Linker = link.NewMemoryFS()
+		if len(settings.Steps) > 0 {
+			planner.Steps = link.NewStepCounter(settings.Steps)
+		}
+
+		names := args[2:]
+		if len(names) == 0 {
+			names, err = readLines(os.Stdin)
+			if err != nil {
+				return err
+			}
+		}
+
+		records := make([]testRecord, 0, len(names))
+		matched := 0
+		for _, name := range names {
+			record := testName(cmd, planner, name)
+			if record.Matched {
+				matched++
+			}
+			records = append(records, record)
+		}
+
+		if isMachineReadableFormat(settings.Format) {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(records)
+		} else {
+			printTestRecords(records, planner, settings)
+		}
+		printStatus(settings, "%d of %d names matched\n", matched, len(records))
+
+		if matched == 0 {
+			return exitWith(ExitNoMatches, "no matching names found")
+		}
+		return nil
+	},
+}
+
+// testName plans a single name, the way a run would plan a path. Steps are
+// only used up by names that match.
+func testName(cmd *cobra.Command, planner *link.Planner, name string) testRecord {
+	record := testRecord{Input: name, Captures: make([]string, 0), Named: make(map[string]string)}
+
+	matches, err := planner.Pattern.Match(name)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	if matches == nil {
+		return record
+	}
+	record.Matched = true
+	record.Captures = matches[1:]
+
+	symlinks, err := planner.Plan(cmd.Context(), []string{name})
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	symlink := symlinks[0]
+	if symlink.Action == link.RefuseAction {
+		record.Error = symlink.Error
+		return record
+	}
+	record.Step = symlink.Step
+	record.StepCount = symlink.StepCount
+	record.Destination = symlink.Destination
+	for i, groupName := range planner.Pattern.SubexpNames()[1:] {
+		if groupName != "" {
+			record.Named[groupName] = symlink.Captures[i]
+		}
+	}
+
+	return record
+}
+
+// printTestRecords prints one row per name, with a column for every capture
+// group of the pattern, named ones labelled with their name.
+func printTestRecords(records []testRecord, planner *link.Planner, settings settings) {
+	headers := []string{"Input"}
+	for i, groupName := range planner.Pattern.SubexpNames()[1:] {
+		header := fmt.Sprintf("$%d", i+1)
+		if groupName != "" {
+			header += " " + groupName
+		}
+		headers = append(headers, header)
+	}
+	if planner.Steps != nil {
+		headers = append(headers, "$STEP", "$STEP_COUNT")
+	}
+	headers = append(headers, "Destination")
+
+	table := table.
+		New().
+		Headers(headers...).
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(styles.Border).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle().Padding(0, 1)
+			if row == table.HeaderRow {
+				return style.Inherit(styles.Header)
+			}
+
+			record := records[row]
+			switch {
+			case record.Error != "":
+				return style.Inherit(styles.Error)
+			case !record.Matched:
+				return style.Inherit(styles.Skipped)
+			case col == len(headers)-1:
+				return style.Inherit(styles.Added)
+			}
+			return style.Inherit(styles.Row)
+		})
+
+	width := columnWidth(settings, len(headers))
+	for _, record := range records {
+		values := slices.Clone(record.Captures)
+		if planner.Steps != nil && record.Matched && record.Error == "" {
+			values = append(values, fmt.Sprint(record.Step), fmt.Sprint(record.StepCount))
+		}
+
+		row := []string{truncatePath(record.Input, width)}
+		for i := range len(headers) - 2 {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			row = append(row, truncateText(value, width))
+		}
+
+		switch {
+		case record.Error != "":
+			row = append(row, truncateText(record.Error, width))
+		case !record.Matched:
+			row = append(row, "no match")
+		default:
+			row = append(row, truncatePath(record.Destination, width))
+		}
+		table.Row(row...)
+	}
+
+	fmt.Println(table)
+}
+
+// readLines reads one name per line, skipping empty lines.
+func readLines(reader io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read names from stdin: %w", err)
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"supalink/link"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+	"github.com/spf13/pflag"
+)
+
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// theme holds every style used by the human-readable output.
+type theme struct {
+	Header       lipgloss.Style
+	Border       lipgloss.Style
+	Row          lipgloss.Style
+	AlternateRow lipgloss.Style
+	Added        lipgloss.Style
+	Unchanged    lipgloss.Style
+	Replaced     lipgloss.Style
+	Conflict     lipgloss.Style
+	Skipped      lipgloss.Style
+	Error        lipgloss.Style
+}
+
+// themeStyle is how a style is written in the theme file. Colors are anything
+// lipgloss understands: ANSI numbers like "3" or hex values like "#ff8800".
+type themeStyle struct {
+	Foreground    string `json:"foreground"`
+	Background    string `json:"background"`
+	Bold          bool   `json:"bold"`
+	Italic        bool   `json:"italic"`
+	Underline     bool   `json:"underline"`
+	Faint         bool   `json:"faint"`
+	Strikethrough bool   `json:"strikethrough"`
+}
+
+// themeFile is the theme file. Every style it sets replaces the default one
+// entirely, and styles it leaves out keep their default.
+type themeFile struct {
+	Header       *themeStyle `json:"header"`
+	Border       *themeStyle `json:"border"`
+	Row          *themeStyle `json:"row"`
+	AlternateRow *themeStyle `json:"alternate_row"`
+	Added        *themeStyle `json:"added"`
+	Unchanged    *themeStyle `json:"unchanged"`
+	Replaced     *themeStyle `json:"replaced"`
+	Conflict     *themeStyle `json:"conflict"`
+	Skipped      *themeStyle `json:"skipped"`
+	Error        *themeStyle `json:"error"`
+}
+
+var styles = defaultTheme()
+
+func defaultTheme() theme {
+	return theme{
+		Header:       lipgloss.NewStyle().Bold(true).Foreground(accentColor),
+		Border:       lipgloss.NewStyle().Foreground(accentColor),
+		Row:          lipgloss.NewStyle().Foreground(lightGrayColor),
+		AlternateRow: lipgloss.NewStyle().Foreground(whiteColor),
+		Added:        lipgloss.NewStyle().Foreground(addedColor),
+		Unchanged:    lipgloss.NewStyle().Foreground(lightGrayColor),
+		Replaced:     lipgloss.NewStyle().Foreground(accentColor),
+		Conflict:     lipgloss.NewStyle().Foreground(conflictColor),
+		Skipped:      lipgloss.NewStyle().Foreground(lightGrayColor).Faint(true),
+		Error:        lipgloss.NewStyle().Bold(true).Foreground(conflictColor),
+	}
+}
+
+// configureStyles applies --color and loads the theme file, if any. It runs
+// before every command, so all output agrees on whether to use colors.
+func configureStyles(flags *pflag.FlagSet) error {
+	color, err := flags.GetString(ColorFlag)
+	if err != nil {
+		return err
+	}
+
+	switch color {
+	case ColorAuto:
+		_, noColor := os.LookupEnv("NO_COLOR")
+		if noColor || !term.IsTerminal(os.Stdout.Fd()) {
+			lipgloss.SetColorProfile(termenv.Ascii)
+		}
+	case ColorAlways:
+		if lipgloss.ColorProfile() == termenv.Ascii {
+			lipgloss.SetColorProfile(termenv.ANSI256)
+		}
+	case ColorNever:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	default:
+		return fmt.Errorf("invalid color value: %s (expected %s, %s or %s)", color, ColorAuto, ColorAlways, ColorNever)
+	}
+
+	themePath, err := flags.GetString(ThemeFlag)
+	if err != nil {
+		return err
+	}
+
+	explicit := themePath != ""
+	if !explicit {
+		configDirectory, err := os.UserConfigDir()
+		if err != nil {
+			return nil
+		}
+		themePath = filepath.Join(configDirectory, "supalink", "theme.json")
+	}
+
+	loaded, err := loadTheme(themePath)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	styles = loaded
+	return nil
+}
+
+func loadTheme(path string) (theme, error) {
+	loaded := defaultTheme()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return loaded, fmt.Errorf("failed to read theme %s: %w", path, err)
+	}
+
+	var file themeFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return loaded, fmt.Errorf("invalid theme %s: %w", path, err)
+	}
+
+	for _, entry := range []struct {
+		style  *lipgloss.Style
+		config *themeStyle
+	}{
+		{&loaded.Header, file.Header},
+		{&loaded.Border, file.Border},
+		{&loaded.Row, file.Row},
+		{&loaded.AlternateRow, file.AlternateRow},
+		{&loaded.Added, file.Added},
+		{&loaded.Unchanged, file.Unchanged},
+		{&loaded.Replaced, file.Replaced},
+		{&loaded.Conflict, file.Conflict},
+		{&loaded.Skipped, file.Skipped},
+		{&loaded.Error, file.Error},
+	} {
+		if entry.config != nil {
+			*entry.style = entry.config.toLipglossStyle()
+		}
+	}
+
+	return loaded, nil
+}
+
+func (s themeStyle) toLipglossStyle() lipgloss.Style {
+	style := lipgloss.NewStyle().
+		Bold(s.Bold).
+		Italic(s.Italic).
+		Underline(s.Underline).
+		Faint(s.Faint).
+		Strikethrough(s.Strikethrough)
+	if s.Foreground != "" {
+		style = style.Foreground(lipgloss.Color(s.Foreground))
+	}
+	if s.Background != "" {
+		style = style.Background(lipgloss.Color(s.Background))
+	}
+	return style
+}
+
+// resultStyle returns the style used for the status of a symlink with the
+// given result.
+func resultStyle(result string) lipgloss.Style {
+	switch result {
+	case link.CreatedResult:
+		return styles.Added
+	case link.ReplacedResult:
+		return styles.Replaced
+	case link.FailedResult:
+		return styles.Error
+	}
+	return styles.Skipped
+}
+
+// actionStyle returns the style used for a symlink with the given action.
+func actionStyle(action string) (lipgloss.Style, bool) {
+	switch action {
+	case link.CreateAction:
+		return styles.Added, true
+	case link.SkipAction:
+		return styles.Unchanged, true
+	case link.ReplaceAction:
+		return styles.Replaced, true
+	case link.ConflictAction, link.RefuseAction:
+		return styles.Conflict, true
+	case link.ExcludeAction:
+		return styles.Skipped, true
+	}
+	return lipgloss.NewStyle(), false
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"supalink/link"
+
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <source path regex> <destination path template>",
+	Short: "Watch the source root directory and link new files once they finish downloading",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srcPath := args[0]
+		destPath := args[1]
+
+		settings, err := getSettings(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		settle, err := cmd.Flags().GetDuration(SettleFlag)
+		if err != nil {
+			return err
+		}
+		if settle <= 0 {
+			return fmt.Errorf("invalid settle duration: %s", settle)
+		}
+
+		planner, err := newPlanner(settings, srcPath, destPath)
+		if err != nil {
+			return err
+		}
+		planner.Replace = settings.Replace
+
+		return watch(planner.RootDirectory(), settle, func(paths []string) {
+			linkNewPaths(cmd.Context(), paths, planner, settings)
+		})
+	},
+}
+
+type pendingFile struct {
+	size      int64
+	changedAt time.Time
+}
+
+// debouncer holds paths reported by the watcher until their size stops
+// changing for the settle duration, so half-downloaded files are not linked.
+type debouncer struct {
+	settle  time.Duration
+	pending map[string]pendingFile
+}
+
+func newDebouncer(settle time.Duration) *debouncer {
+	return &debouncer{settle: settle, pending: make(map[string]pendingFile)}
+}
+
+func (d *debouncer) Touch(path string, now time.Time) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	d.pending[path] = pendingFile{size: info.Size(), changedAt: now}
+}
+
+func (d *debouncer) Settled(now time.Time) []string {
+	settled := make([]string, 0)
+	for path, file := range d.pending {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(d.pending, path)
+			continue
+		}
+
+		if info.Size() != file.size {
+			d.pending[path] = pendingFile{size: info.Size(), changedAt: now}
+			continue
+		}
+
+		if now.Sub(file.changedAt) >= d.settle {
+			settled = append(settled, path)
+			delete(d.pending, path)
+		}
+	}
+
+	sort.Strings(settled)
+	return settled
+}
+
+// linkNewPaths plans the whole source tree again, so step numbering stays the
+// same as for a regular run, and links the settled paths.
+func linkNewPaths(ctx context.Context, paths []string, planner *link.Planner, settings settings) {
+	if len(settings.Steps) > 0 {
+		planner.Steps = link.NewStepCounter(settings.Steps)
+	}
+
+	all, err := planner.Walk(ctx)
+	if err != nil {
+		slog.Error("Failed to list source paths", "error", err)
+		return
+	}
+	plan, err := planner.Plan(ctx, all)
+	if err != nil {
+		slog.Error("Failed to plan symlinks", "error", err)
+		return
+	}
+	plan.MarkCollisions()
+
+	planned := make(map[string]link.PlanItem, len(plan))
+	for _, item := range plan {
+		planned[item.Source] = item
+	}
+
+	pending := make(link.Plan, 0, len(paths))
+	for _, source := range paths {
+		item, ok := planned[source]
+		if !ok {
+			slog.Debug("Ignoring path not matching the source pattern", "path", source)
+			continue
+		}
+		pending = append(pending, item)
+	}
+
+	if settings.DryRun {
+		for _, item := range pending {
+			if item.Action == link.CreateAction || item.Action == link.ReplaceAction {
+				slog.Info("Would create symlink", "source", item.Source, "destination", item.Destination)
+			}
+		}
+		return
+	}
+
+	applier := &link.Applier{}
+	applier.Apply(ctx, pending)
+
+	for _, item := range pending {
+		switch {
+		case item.Result == link.FailedResult && item.Action == link.RefuseAction:
+			slog.Warn("Skipping path with an unsafe destination", "source", item.Source, "destination", item.Destination, "error", item.Error)
+		case item.Result == link.FailedResult && item.Action == link.ConflictAction:
+			slog.Warn("Skipping path, destination already exists", "source", item.Source, "destination", item.Destination, "error", item.Error)
+		case item.Result == link.FailedResult:
+			slog.Error("Failed to create symlink", "source", item.Source, "destination", item.Destination, "error", item.Error)
+		case item.Result == link.SkippedResult:
+			slog.Info("Symlink already exists", "source", item.Source, "destination", item.Destination)
+		case item.Result == link.ReplacedResult:
+			slog.Info("Symlink replaced", "source", item.Source, "destination", item.Destination)
+		default:
+			slog.Info("Symlink created", "source", item.Source, "destination", item.Destination)
+		}
+	}
+}
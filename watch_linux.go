@@ -0,0 +1,140 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO
+
+type inotifyWatcher struct {
+	fd          int
+	directories map[int]string
+}
+
+func watch(rootDirectory string, settle time.Duration, onSettled func([]string)) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+	defer unix.Close(fd)
+
+	watcher := &inotifyWatcher{fd: fd, directories: make(map[int]string)}
+	if err := watcher.addRecursive(rootDirectory, nil); err != nil {
+		return err
+	}
+	slog.Info("Watching for new files", "root", rootDirectory)
+
+	events := make(chan string)
+	errs := make(chan error, 1)
+	go watcher.read(events, errs)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(min(settle, time.Second))
+	defer ticker.Stop()
+
+	debouncer := newDebouncer(settle)
+	for {
+		select {
+		case path := <-events:
+			debouncer.Touch(path, time.Now())
+		case <-ticker.C:
+			if settled := debouncer.Settled(time.Now()); len(settled) > 0 {
+				onSettled(settled)
+			}
+		case err := <-errs:
+			return err
+		case <-signals:
+			slog.Info("Stopped watching", "root", rootDirectory)
+			return nil
+		}
+	}
+}
+
+// addRecursive watches directory and every directory below it. Files found
+// along the way are passed to onFile, which covers directories that are moved
+// into the watched tree with their contents already in place.
+func (w *inotifyWatcher) addRecursive(directory string, onFile func(string)) error {
+	return filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			if onFile != nil {
+				onFile(path)
+			}
+			return nil
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		w.directories[wd] = path
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) read(events chan<- string, errs chan<- error) {
+	buffer := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := unix.Read(w.fd, buffer)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			errs <- fmt.Errorf("failed to read inotify events: %w", err)
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				slog.Warn("Inotify event queue overflowed, some new files may have been missed")
+				continue
+			}
+
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(w.directories, int(event.Wd))
+				continue
+			}
+
+			directory, ok := w.directories[int(event.Wd)]
+			if !ok || name == "" {
+				continue
+			}
+			path := filepath.Join(directory, name)
+
+			if event.Mask&unix.IN_ISDIR != 0 {
+				if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					err := w.addRecursive(path, func(file string) { events <- file })
+					if err != nil {
+						slog.Error("Failed to watch new directory", "path", path, "error", err)
+					}
+				}
+				continue
+			}
+
+			events <- path
+		}
+	}
+}
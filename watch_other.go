@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func watch(rootDirectory string, settle time.Duration, onSettled func([]string)) error {
+	return fmt.Errorf("watch mode is only supported on linux")
+}